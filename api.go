@@ -26,22 +26,73 @@ package speedtest
 
 import (
 	"encoding/xml"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 )
 
+const (
+	// DefaultSettingsURL is the location of the speedtest.net server list
+	DefaultSettingsURL = "http://www.speedtest.net/speedtest-servers.php"
+	// DefaultConfigURL is the location of the speedtest.net client config
+	DefaultConfigURL = "http://www.speedtest.net/speedtest-config.php"
+)
+
+// API fetches data from the speedtest.net API using a specific HTTP client
+type API struct {
+	Client      http.Client
+	SettingsURL string
+	ConfigURL   string
+	// Trace, if set, records the phase timings of each request
+	Trace *TraceRecorder
+}
+
+// NewAPI creates an API that makes requests with the given HTTP client
+func NewAPI(client http.Client) API {
+	return API{
+		Client:      client,
+		SettingsURL: DefaultSettingsURL,
+		ConfigURL:   DefaultConfigURL,
+	}
+}
+
 // Fetch GETs a URL and returns the response body
-func Fetch(url string) ([]byte, error) {
-	resp, err := http.Get(url)
+func (a API) Fetch(url string) ([]byte, error) {
+	resp, body, err := a.fetch(url, nil)
 	if err != nil {
 		return nil, err
 	}
-	return ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response: %v", resp.Status)
+	}
+	return body, nil
+}
+
+// fetch GETs a URL with the given headers, returning the response and its
+// body
+func (a API) fetch(url string, header http.Header) (*http.Response, []byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req, finish := a.Trace.Trace(req)
+	defer finish()
+
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	return resp, body, err
 }
 
 // FetchSettings fetches the list of available servers
-func FetchSettings() (Settings, error) {
-	body, err := Fetch("http://www.speedtest.net/speedtest-servers.php")
+func (a API) FetchSettings() (Settings, error) {
+	body, err := a.Fetch(a.SettingsURL)
 	if err != nil {
 		return Settings{}, err
 	}
@@ -51,8 +102,8 @@ func FetchSettings() (Settings, error) {
 }
 
 // FetchConfig fetches the recommended client configuration
-func FetchConfig() (Config, error) {
-	body, err := Fetch("http://www.speedtest.net/speedtest-config.php")
+func (a API) FetchConfig() (Config, error) {
+	body, err := a.Fetch(a.ConfigURL)
 	if err != nil {
 		return Config{}, err
 	}
@@ -60,3 +111,20 @@ func FetchConfig() (Config, error) {
 	err = xml.Unmarshal(body, &config)
 	return config, err
 }
+
+// Fetch GETs a URL using the default HTTP client and returns the response body
+func Fetch(url string) ([]byte, error) {
+	return NewAPI(*http.DefaultClient).Fetch(url)
+}
+
+// FetchSettings fetches the list of available servers using the default HTTP
+// client
+func FetchSettings() (Settings, error) {
+	return NewAPI(*http.DefaultClient).FetchSettings()
+}
+
+// FetchConfig fetches the recommended client configuration using the default
+// HTTP client
+func FetchConfig() (Config, error) {
+	return NewAPI(*http.DefaultClient).FetchConfig()
+}
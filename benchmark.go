@@ -27,12 +27,14 @@ package speedtest
 import (
 	"errors"
 	"io"
+	"io/ioutil"
 	"log"
 	"math/rand"
 	"net/http"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -42,7 +44,7 @@ const (
 )
 
 // ErrTimeExpired is returned by readers/writers if they were halted due to
-// exceeding the duration of the benchmark.
+// exceeding the duration, or data budget, of the benchmark.
 var ErrTimeExpired = errors.New("time expired")
 
 // A Benchmark represents a specific bandwidth test.
@@ -55,21 +57,36 @@ type DownloadBenchmark struct {
 	Client  http.Client
 	Server  Server
 	BaseURL string
+	// Trace, if set, records the phase timings of each request.
+	Trace *TraceRecorder
 }
 
 // NewDownloadBenchmark creates a new download benchmark with the given HTTP
 // client and test server.
 func NewDownloadBenchmark(client http.Client, server Server) DownloadBenchmark {
+	baseURL := serverPath(server, "random1000x1000.jpg")
+	return DownloadBenchmark{client, server, baseURL, nil}
+}
+
+// serverPath returns the URL of the named file alongside the server's upload
+// script.
+func serverPath(server Server, name string) string {
 	slashPos := strings.LastIndex(server.URL, "/")
-	baseURL := server.URL[:slashPos] + "/random1000x1000.jpg"
-	return DownloadBenchmark{client, server, baseURL}
+	return server.URL[:slashPos] + "/" + name
 }
 
 // Run fetches a file, reporting the size of each downloaded chunk to the
 // callback function, ending only on EOF or when the callback returns an error.
 func (b DownloadBenchmark) Run(fn func(n int) error) error {
 	threadURL := b.BaseURL + "?x=" + strconv.Itoa(rand.Int())
-	resp, err := b.Client.Get(threadURL)
+	req, err := http.NewRequest("GET", threadURL, nil)
+	if err != nil {
+		return err
+	}
+	req, finish := b.Trace.Trace(req)
+	defer finish()
+
+	resp, err := b.Client.Do(req)
 	if err != nil {
 		return err
 	}
@@ -92,16 +109,25 @@ func (b DownloadBenchmark) Run(fn func(n int) error) error {
 	return nil
 }
 
+// WithTrace returns a copy of the benchmark that records the phase timings of
+// its requests to the given recorder.
+func (b DownloadBenchmark) WithTrace(r *TraceRecorder) Benchmark {
+	b.Trace = r
+	return b
+}
+
 // UploadBenchmark represents an upload bandwidth test.
 type UploadBenchmark struct {
 	Client http.Client
 	Server Server
+	// Trace, if set, records the phase timings of each request.
+	Trace *TraceRecorder
 }
 
 // NewUploadBenchmark creates a new upload benchmark with the given HTTP
 // client and test server.
 func NewUploadBenchmark(client http.Client, server Server) UploadBenchmark {
-	return UploadBenchmark{client, server}
+	return UploadBenchmark{client, server, nil}
 }
 
 // Run performs an HTTP POST, uploading junk data and reporting the size of
@@ -110,85 +136,586 @@ func (b UploadBenchmark) Run(fn func(n int) error) error {
 	reader := NewJunkReader(1024 * 1024)
 	writer := NewCallbackWriter(fn)
 	tee := io.TeeReader(&reader, writer)
-	_, err := b.Client.Post(b.Server.URL, "text/plain", tee)
+	req, err := http.NewRequest("POST", b.Server.URL, tee)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	req, finish := b.Trace.Trace(req)
+	defer finish()
+
+	resp, err := b.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(ioutil.Discard, resp.Body)
 	return err
 }
 
+// WithTrace returns a copy of the benchmark that records the phase timings of
+// its requests to the given recorder.
+func (b UploadBenchmark) WithTrace(r *TraceRecorder) Benchmark {
+	b.Trace = r
+	return b
+}
+
+// MultiBenchmark spreads requests across several benchmarks, typically
+// against different servers, so that their transfers are sampled as one.
+type MultiBenchmark struct {
+	Benchmarks []Benchmark
+	next       *uint32
+	bytes      []int64
+}
+
+// NewMultiBenchmark creates a benchmark that runs each of the given
+// benchmarks in turn.
+func NewMultiBenchmark(benchmarks ...Benchmark) MultiBenchmark {
+	return MultiBenchmark{
+		Benchmarks: benchmarks,
+		next:       new(uint32),
+		bytes:      make([]int64, len(benchmarks)),
+	}
+}
+
+// Run runs the next benchmark in turn, tallying the data it transfers.
+func (b MultiBenchmark) Run(fn func(n int) error) error {
+	i := int(atomic.AddUint32(b.next, 1)-1) % len(b.Benchmarks)
+	return b.Benchmarks[i].Run(func(n int) error {
+		atomic.AddInt64(&b.bytes[i], int64(n))
+		return fn(n)
+	})
+}
+
+// WithTrace returns a copy of the benchmark whose parts record the phase
+// timings of their requests to the given recorder, if they are Traceable.
+func (b MultiBenchmark) WithTrace(r *TraceRecorder) Benchmark {
+	benchmarks := make([]Benchmark, len(b.Benchmarks))
+	for i, benchmark := range b.Benchmarks {
+		if t, ok := benchmark.(Traceable); ok {
+			benchmark = t.WithTrace(r)
+		}
+		benchmarks[i] = benchmark
+	}
+	b.Benchmarks = benchmarks
+	return b
+}
+
+// A Contributor is a benchmark made up of parts, such as a MultiBenchmark,
+// that tallies the number of bytes transferred by each part.
+type Contributor interface {
+	Bytes() []int64
+}
+
+// Bytes returns the number of bytes transferred by each benchmark.
+func (b MultiBenchmark) Bytes() []int64 {
+	bytes := make([]int64, len(b.bytes))
+	for i := range b.bytes {
+		bytes[i] = atomic.LoadInt64(&b.bytes[i])
+	}
+	return bytes
+}
+
+// BenchmarkOptions describes how a Benchmark should be run.
+type BenchmarkOptions struct {
+	// Threads is the initial number of concurrent requests.
+	Threads int
+	// MaxThreads is the maximum number of concurrent requests.
+	MaxThreads int
+	// Duration is the length of the testing period.
+	Duration time.Duration
+	// Pinger, if set, is used to probe latency continuously while the
+	// benchmark runs.
+	Pinger Pinger
+	// PingInterval is the delay between latency probes. Defaults to 200ms.
+	PingInterval time.Duration
+	// Scaling is the strategy used to choose the number of threads.
+	Scaling Scaling
+	// Convergence, if set, ends the benchmark before Duration has elapsed
+	// once the measured rate is stable.
+	Convergence *Convergence
+	// WarmUp is a grace period at the start of the benchmark whose samples
+	// are excluded from the estimated rate.
+	WarmUp time.Duration
+	// AutoWarmUp, if true, excludes samples recorded before the rate stops
+	// ramping up, if that is later than WarmUp. At most half of the samples
+	// are excluded.
+	AutoWarmUp bool
+	// MaxBytes, if positive, ends the benchmark once this many bytes have
+	// been transferred. Requests already in flight may overshoot slightly.
+	MaxBytes int64
+	// IdleLatency holds latencies measured while the line was idle. If a
+	// Pinger is set and IdleLatency is empty, it is measured before the
+	// benchmark starts.
+	IdleLatency Latencies
+	// Progress, if set, is called after each sampling interval while the
+	// benchmark runs.
+	Progress func(Progress)
+}
+
+// Progress describes a benchmark while it runs.
+type Progress struct {
+	// Elapsed is how long the benchmark has been running.
+	Elapsed time.Duration
+	// Samples holds the number of bytes transferred in each sampling
+	// interval completed so far.
+	Samples []int
+	// Resolution is the duration of each sampling interval.
+	Resolution time.Duration
+	// Threads is the number of concurrent requests.
+	Threads int
+	// Bytes is the number of bytes transferred so far.
+	Bytes int64
+	// Latency is the most recent latency measured under load, if any.
+	Latency time.Duration
+}
+
+// BenchmarkResult holds the outcome of a benchmark run. In JSON, durations
+// and latencies are encoded as integer nanoseconds.
+type BenchmarkResult struct {
+	// Rate is the estimated transfer rate, in bytes/sec.
+	Rate int `json:"rate"`
+	// RateLow and RateHigh bound the confidence interval of Rate at the
+	// Confidence level, in bytes/sec.
+	RateLow  int `json:"rateLow"`
+	RateHigh int `json:"rateHigh"`
+	// Variation is the coefficient of variation of the one second rates
+	// from which Rate was estimated.
+	Variation float64 `json:"variation"`
+	// Samples holds the number of bytes transferred in each sampling
+	// interval of the testing period.
+	Samples []int `json:"samples"`
+	// Resolution is the duration of each sampling interval.
+	Resolution time.Duration `json:"resolution"`
+	// Duration is how long the benchmark ran for.
+	Duration time.Duration `json:"duration"`
+	// Converged is true if the benchmark ended early because the measured
+	// rate was stable.
+	Converged bool `json:"converged,omitempty"`
+	// WarmUp is the period at the start of the benchmark excluded from the
+	// estimated rate.
+	WarmUp time.Duration `json:"warmUp,omitempty"`
+	// WarmUpBytes is the number of bytes transferred during WarmUp.
+	WarmUpBytes int `json:"warmUpBytes,omitempty"`
+	// MeanRate is the mean transfer rate over the whole benchmark, in
+	// bytes/sec, including WarmUp.
+	MeanRate int `json:"meanRate"`
+	// Bytes is the total number of bytes transferred.
+	Bytes int64 `json:"bytes"`
+	// MaxBytes is the data cap the benchmark ran under, or zero if none.
+	MaxBytes int64 `json:"maxBytes,omitempty"`
+	// CapReached is true if the benchmark ended because MaxBytes were
+	// transferred.
+	CapReached bool `json:"capReached,omitempty"`
+	// Convergence holds the criteria for ending the benchmark early, if
+	// any.
+	Convergence *Convergence `json:"convergence,omitempty"`
+	// Timings holds the phase timings of requests made by a Traceable
+	// benchmark.
+	Timings PhaseTimings `json:"timings"`
+	// Protocol is the HTTP version used by a Traceable benchmark.
+	Protocol string `json:"protocol,omitempty"`
+	// TLSVersion is the TLS version used by a Traceable benchmark, if any.
+	TLSVersion string `json:"tlsVersion,omitempty"`
+	// Scaling is the strategy that chose the number of threads.
+	Scaling Scaling `json:"scaling,omitempty"`
+	// Threads is the number of concurrent requests chosen by the scaling
+	// strategy when the testing period ended.
+	Threads int `json:"threads"`
+	// IdleLatency holds latencies measured while the line was idle.
+	IdleLatency Latencies `json:"idleLatency,omitempty"`
+	// LoadedLatency holds latencies measured while the benchmark ran.
+	LoadedLatency Latencies `json:"loadedLatency,omitempty"`
+	// Bufferbloat grades the increase in latency under load, if measured.
+	Bufferbloat string `json:"bufferbloat,omitempty"`
+	// Contributions holds the number of bytes transferred by each part of a
+	// Contributor, such as a MultiBenchmark.
+	Contributions []int64 `json:"contributions,omitempty"`
+}
+
 // RunBenchmark runs the given benchmark for the given amount of time. It
 // increases the number of threads up to the maximum as each one finishes.
 // The returned value is the maximum number of bytes recorded from any
 // contiguous 1 second window within the testing period.
 func RunBenchmark(b Benchmark, threads int, maxThreads int, duration time.Duration) int {
+	return Measure(b, BenchmarkOptions{
+		Threads:    threads,
+		MaxThreads: maxThreads,
+		Duration:   duration,
+	}).Rate
+}
+
+// Measure runs the given benchmark as described by opts, returning the
+// estimated rate along with the samples it was derived from.
+func Measure(b Benchmark, opts BenchmarkOptions) BenchmarkResult {
 	var wg sync.WaitGroup
-	var tc sync.Mutex
+	var mu sync.Mutex
+
+	// Record request phase timings
+	var trace *TraceRecorder
+	if t, ok := b.(Traceable); ok {
+		trace = NewTraceRecorder()
+		b = t.WithTrace(trace)
+	}
+
+	// Tally the parts of the benchmark from their counts so far
+	contributor, isContributor := b.(Contributor)
+	var contributions []int64
+	if isContributor {
+		contributions = contributor.Bytes()
+	}
+
+	// Measure latency of the idle line before loading it
+	idle := opts.IdleLatency
+	if opts.Pinger != nil && len(idle) == 0 {
+		idle, _ = MeasureLatency(opts.Pinger, IdlePings)
+	}
 
 	// Setup sampling parameters
 	resolution := time.Second / time.Duration(windowSize)
-	chunks := make([]int, duration/resolution)
+	chunks := make([]int, opts.Duration/resolution)
 
-	// Seed thread pool
-	reqs := make(chan int, maxThreads)
+	// Seed thread pool. The target may drop below the number of threads in
+	// use, in which case threads retire as their requests complete.
+	threads := opts.Threads
+	target := threads
+	reqs := make(chan int, opts.MaxThreads)
 	for i := 0; i < threads; i++ {
 		reqs <- 1
 	}
 
-	// Setup timeout
+	// Setup timeout and data budget
 	start := time.Now()
-	active := true
+	done := make(chan struct{})
+	capped := make(chan struct{})
+	var capOnce sync.Once
+	expired := func() bool {
+		select {
+		case <-done:
+			return true
+		case <-capped:
+			return true
+		default:
+			return false
+		}
+	}
 
+	var total int64
 	perform := func() {
-		wg.Add(1)
 		defer wg.Done()
 
 		// Run benchmark, recording reads into timestamped array
 		err := b.Run(func(n int) error {
+			sum := atomic.AddInt64(&total, int64(n))
+			if opts.MaxBytes > 0 && sum >= opts.MaxBytes {
+				capOnce.Do(func() { close(capped) })
+			}
 			p := int(time.Since(start) / resolution)
+			mu.Lock()
 			if p < len(chunks) {
 				chunks[p] += n
 			}
-			if !active {
+			mu.Unlock()
+			if expired() {
 				return ErrTimeExpired
 			}
 			return nil
 		})
 
-		if active {
-			if err != nil {
-				log.Fatalln(err)
-			}
+		if expired() {
+			// All done!
+			return
+		}
 
-			// Enqueue next task
-			reqs <- 1
+		if err != nil {
+			log.Fatalln(err)
+		}
 
-			// See if we can add another thread
-			tc.Lock()
-			if threads < maxThreads {
-				threads++
-				reqs <- 1
-			}
-			tc.Unlock()
-		} else {
-			// All done!
+		mu.Lock()
+		defer mu.Unlock()
+
+		// Retire this thread if there are too many
+		if threads > target {
+			threads--
 			return
 		}
+
+		// Enqueue next task
+		reqs <- 1
+
+		// See if we can add another thread
+		if opts.Scaling == ScaleOnCompletion && threads < opts.MaxThreads {
+			threads++
+			target++
+			reqs <- 1
+		}
+	}
+
+	var helpers sync.WaitGroup
+
+	// Probe latency under load
+	var loaded Latencies
+	var latest int64
+	if opts.Pinger != nil {
+		interval := opts.PingInterval
+		if interval == 0 {
+			interval = pingInterval
+		}
+		helpers.Add(1)
+		go func() {
+			defer helpers.Done()
+			loaded = probeLatency(opts.Pinger, interval, done, &latest)
+		}()
+	}
+
+	// Adjust the number of threads by measured throughput
+	if opts.Scaling == ScaleAdaptive {
+		helpers.Add(1)
+		go func() {
+			defer helpers.Done()
+			s := scaler{MaxThreads: opts.MaxThreads}
+			ticker := time.NewTicker(scaleInterval)
+			defer ticker.Stop()
+			var last int64
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+				}
+				n := atomic.LoadInt64(&total)
+				rate := float64(n-last) / scaleInterval.Seconds()
+				last = n
+
+				mu.Lock()
+				switch delta := s.Adjust(target, rate); {
+				case delta > 0:
+					target++
+					if threads < target {
+						threads++
+						reqs <- 1
+					}
+				case delta < 0:
+					target--
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	// End early once the rate has stabilised
+	converged := make(chan struct{})
+	if c := opts.Convergence; c != nil {
+		helpers.Add(1)
+		go func() {
+			defer helpers.Done()
+			size := int(c.Window / resolution)
+			ticker := time.NewTicker(resolution)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+				}
+				elapsed := time.Since(start)
+				if elapsed < c.MinDuration {
+					continue
+				}
+				n := int(elapsed / resolution)
+				if n > len(chunks) {
+					n = len(chunks)
+				}
+				mu.Lock()
+				stable := Converged(chunks[:n], size, c.Threshold)
+				mu.Unlock()
+				if stable {
+					close(converged)
+					return
+				}
+			}
+		}()
+	}
+
+	// Report progress
+	if opts.Progress != nil {
+		helpers.Add(1)
+		go func() {
+			defer helpers.Done()
+			ticker := time.NewTicker(resolution)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+				}
+				elapsed := time.Since(start)
+				n := int(elapsed / resolution)
+				if n > len(chunks) {
+					n = len(chunks)
+				}
+				mu.Lock()
+				samples := make([]int, n)
+				copy(samples, chunks)
+				p := Progress{
+					Elapsed:    elapsed,
+					Samples:    samples,
+					Resolution: resolution,
+					Threads:    threads,
+				}
+				mu.Unlock()
+				p.Bytes = atomic.LoadInt64(&total)
+				p.Latency = time.Duration(atomic.LoadInt64(&latest))
+				opts.Progress(p)
+			}
+		}()
 	}
 
 	// Process queue
-	timeout := time.After(duration)
-	for active {
+	timeout := time.After(opts.Duration)
+	isConverged, isCapped := false, false
+	for running := true; running; {
 		select {
 		case <-reqs:
-			if active {
-				go perform()
-			}
+			wg.Add(1)
+			go perform()
+		case <-converged:
+			isConverged = true
+			close(done)
+			running = false
+		case <-capped:
+			isCapped = true
+			close(done)
+			running = false
 		case <-timeout:
 			// Outta time, signal
-			active = false
+			close(done)
+			running = false
 		}
 	}
+	elapsed := time.Since(start)
 
 	wg.Wait()
+	helpers.Wait()
 
+	// Discard samples recorded after the benchmark ended early
+	if n := int(elapsed / resolution); n < len(chunks) {
+		chunks = chunks[:n]
+	}
+
+	// Exclude samples recorded while connections were warming up
+	warm := int(opts.WarmUp / resolution)
+	if opts.AutoWarmUp {
+		if end := RampEnd(chunks, windowSize); end > warm {
+			warm = end
+		}
+	}
+	if warm > len(chunks)/2 {
+		warm = len(chunks) / 2
+	}
+	warmBytes, totalBytes := 0, 0
+	for i, n := range chunks {
+		if i < warm {
+			warmBytes += n
+		}
+		totalBytes += n
+	}
+	meanRate := 0
+	if len(chunks) > 0 {
+		seconds := float64(len(chunks)) * resolution.Seconds()
+		meanRate = int(float64(totalBytes) / seconds)
+	}
+
+	if isContributor {
+		for i, n := range contributor.Bytes() {
+			if i < len(contributions) {
+				contributions[i] = n - contributions[i]
+			}
+		}
+	}
+
+	proto, tlsVersion := trace.Protocol()
+	low, high := BootstrapRate(chunks[warm:], Confidence)
+
+	return BenchmarkResult{
+		Rate:          estimateRate(chunks[warm:]),
+		RateLow:       low,
+		RateHigh:      high,
+		Variation:     Variation(chunks[warm:], windowSize),
+		Samples:       chunks,
+		Resolution:    resolution,
+		Duration:      elapsed,
+		Converged:     isConverged,
+		WarmUp:        time.Duration(warm) * resolution,
+		WarmUpBytes:   warmBytes,
+		MeanRate:      meanRate,
+		Bytes:         atomic.LoadInt64(&total),
+		MaxBytes:      opts.MaxBytes,
+		CapReached:    isCapped,
+		Convergence:   opts.Convergence,
+		Timings:       trace.Timings(),
+		Protocol:      proto,
+		TLSVersion:    tlsVersion,
+		Scaling:       opts.Scaling,
+		Threads:       target,
+		IdleLatency:   idle,
+		LoadedLatency: loaded,
+		Bufferbloat:   BufferbloatGrade(idle, loaded),
+		Contributions: contributions,
+	}
+}
+
+// MeasureBidirectional runs the download and upload benchmarks
+// simultaneously, each as described by opts, and returns their results. Each
+// direction is sampled separately, so each result reflects that direction's
+// rate while the other is under load.
+func MeasureBidirectional(down, up Benchmark, opts BenchmarkOptions) (BenchmarkResult, BenchmarkResult) {
+	return MeasureBidirectionalWith(down, up, opts, opts)
+}
+
+// MeasureBidirectionalWith is like MeasureBidirectional, but runs each
+// direction with its own options, for example to report their progress
+// separately. Idle latency is measured once using the download options.
+func MeasureBidirectionalWith(down, up Benchmark, downOpts, upOpts BenchmarkOptions) (BenchmarkResult, BenchmarkResult) {
+	var wg sync.WaitGroup
+	var downResult, upResult BenchmarkResult
+
+	// Measure idle latency once, before either direction loads the line
+	if downOpts.Pinger != nil && len(downOpts.IdleLatency) == 0 {
+		downOpts.IdleLatency, _ = MeasureLatency(downOpts.Pinger, IdlePings)
+	}
+	if len(upOpts.IdleLatency) == 0 {
+		upOpts.IdleLatency = downOpts.IdleLatency
+	}
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		downResult = Measure(down, downOpts)
+	}()
+	go func() {
+		defer wg.Done()
+		upResult = Measure(up, upOpts)
+	}()
+	wg.Wait()
+
+	return downResult, upResult
+}
+
+// estimateRate combines the best and median one second windows within the
+// given samples to produce an estimated rate. Samples spanning less than a
+// window, as when a data cap ends a run early, are scaled up to one.
+func estimateRate(chunks []int) int {
+	if len(chunks) == 0 {
+		return 0
+	} else if len(chunks) < windowSize {
+		total := 0
+		for _, n := range chunks {
+			total += n
+		}
+		return total * windowSize / len(chunks)
+	}
 	maxSum := MaximalSumWindow(chunks, windowSize)
 	windowAvg := MedianSumWindow(chunks, windowSize)
 	return (maxSum + windowAvg) / 2
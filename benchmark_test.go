@@ -0,0 +1,181 @@
+package speedtest
+
+import (
+	"encoding/json"
+	. "github.com/smartystreets/goconvey/convey"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBenchmark reports a fixed number of bytes at a fixed interval until the
+// callback returns an error.
+type fakeBenchmark struct {
+	Chunk    int
+	Interval time.Duration
+}
+
+func (b fakeBenchmark) Run(fn func(n int) error) error {
+	for {
+		time.Sleep(b.Interval)
+		if err := fn(b.Chunk); err != nil {
+			if err == ErrTimeExpired {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func Test_MeasureBidirectional(t *testing.T) {
+	Convey("Each direction should be sampled separately", t, func() {
+		down := fakeBenchmark{Chunk: 1000, Interval: 10 * time.Millisecond}
+		up := fakeBenchmark{Chunk: 10, Interval: 10 * time.Millisecond}
+		opts := BenchmarkOptions{
+			Threads:    1,
+			MaxThreads: 1,
+			Duration:   time.Second,
+		}
+
+		downResult, upResult := MeasureBidirectional(down, up, opts)
+		So(len(downResult.Samples), ShouldEqual, 10)
+		So(len(upResult.Samples), ShouldEqual, 10)
+		So(downResult.Rate, ShouldBeGreaterThan, upResult.Rate)
+		So(upResult.Rate, ShouldBeGreaterThan, 0)
+	})
+}
+
+func Test_MultiBenchmark(t *testing.T) {
+	Convey("Contributions should be tallied per benchmark", t, func() {
+		b := NewMultiBenchmark(
+			fakeBenchmark{Chunk: 1000, Interval: 10 * time.Millisecond},
+			fakeBenchmark{Chunk: 10, Interval: 10 * time.Millisecond})
+		opts := BenchmarkOptions{
+			Threads:    2,
+			MaxThreads: 2,
+			Duration:   time.Second,
+		}
+
+		result := Measure(b, opts)
+		So(len(result.Contributions), ShouldEqual, 2)
+		So(result.Contributions[0], ShouldBeGreaterThan, result.Contributions[1])
+		So(result.Contributions[1], ShouldBeGreaterThan, 0)
+		So(b.Bytes(), ShouldResemble, result.Contributions)
+
+		again := Measure(b, opts)
+		So(again.Contributions[1], ShouldBeGreaterThan, 0)
+		So(again.Contributions[1], ShouldBeLessThan, b.Bytes()[1])
+	})
+
+	Convey("Contributions should be read from any Contributor", t, func() {
+		b := wrappedMulti{NewMultiBenchmark(
+			fakeBenchmark{Chunk: 1000, Interval: 10 * time.Millisecond},
+			fakeBenchmark{Chunk: 10, Interval: 10 * time.Millisecond})}
+		opts := BenchmarkOptions{
+			Threads:    2,
+			MaxThreads: 2,
+			Duration:   500 * time.Millisecond,
+		}
+
+		result := Measure(b, opts)
+		So(len(result.Contributions), ShouldEqual, 2)
+		So(result.Contributions, ShouldResemble, b.Bytes())
+	})
+}
+
+// wrappedMulti is a user-defined benchmark wrapping a MultiBenchmark.
+type wrappedMulti struct {
+	multi MultiBenchmark
+}
+
+func (b wrappedMulti) Run(fn func(n int) error) error { return b.multi.Run(fn) }
+
+func (b wrappedMulti) Bytes() []int64 { return b.multi.Bytes() }
+
+func Test_MeasureMaxBytes(t *testing.T) {
+	Convey("A benchmark should end once its data budget is spent", t, func() {
+		b := fakeBenchmark{Chunk: 1000, Interval: time.Millisecond}
+		opts := BenchmarkOptions{
+			Threads:    2,
+			MaxThreads: 2,
+			Duration:   10 * time.Second,
+			MaxBytes:   500000,
+		}
+
+		result := Measure(b, opts)
+		So(result.CapReached, ShouldBeTrue)
+		So(result.Bytes, ShouldBeGreaterThanOrEqualTo, 500000)
+		So(result.Bytes, ShouldBeLessThan, 510000)
+		So(result.Duration, ShouldBeLessThan, 5*time.Second)
+	})
+
+	Convey("A run capped within a second should still estimate its rate", t, func() {
+		b := fakeBenchmark{Chunk: 1000, Interval: time.Millisecond}
+		result := Measure(b, BenchmarkOptions{
+			Threads:    2,
+			MaxThreads: 2,
+			Duration:   10 * time.Second,
+			MaxBytes:   300000,
+		})
+		So(result.CapReached, ShouldBeTrue)
+		So(len(result.Samples), ShouldBeLessThan, windowSize)
+		So(result.Rate, ShouldBeBetween, result.MeanRate*9/10, result.MeanRate*11/10)
+	})
+}
+
+func Test_MeasureProgress(t *testing.T) {
+	Convey("Progress should be reported while a benchmark runs", t, func() {
+		var mu sync.Mutex
+		var updates []Progress
+		b := fakeBenchmark{Chunk: 1000, Interval: 10 * time.Millisecond}
+		result := Measure(b, BenchmarkOptions{
+			Threads:    2,
+			MaxThreads: 2,
+			Duration:   time.Second,
+			Progress: func(p Progress) {
+				mu.Lock()
+				updates = append(updates, p)
+				mu.Unlock()
+			},
+		})
+
+		mu.Lock()
+		defer mu.Unlock()
+		So(len(updates), ShouldBeGreaterThan, 5)
+		last := updates[len(updates)-1]
+		So(len(last.Samples), ShouldBeGreaterThan, len(updates[0].Samples))
+		So(last.Threads, ShouldEqual, 2)
+		So(last.Bytes, ShouldBeGreaterThan, 0)
+		So(last.Bytes, ShouldBeLessThanOrEqualTo, result.Bytes)
+		So(last.Resolution, ShouldEqual, result.Resolution)
+	})
+}
+
+func Test_BenchmarkResultJSON(t *testing.T) {
+	Convey("Results should be encoded with lowercase keys and nanoseconds", t, func() {
+		result := BenchmarkResult{
+			Rate:          1250000,
+			Samples:       []int{125000},
+			Resolution:    100 * time.Millisecond,
+			CapReached:    true,
+			LoadedLatency: Latencies{30 * time.Millisecond},
+			Timings:       PhaseTimings{TTFB: Latencies{time.Millisecond}},
+		}
+		data, err := json.Marshal(result)
+		So(err, ShouldBeNil)
+
+		var fields map[string]interface{}
+		So(json.Unmarshal(data, &fields), ShouldBeNil)
+		So(fields["rate"], ShouldEqual, 1250000)
+		So(fields["resolution"], ShouldEqual, 1e8)
+		So(fields["capReached"], ShouldEqual, true)
+		So(fields["loadedLatency"], ShouldResemble, []interface{}{3e7})
+		So(fields["timings"], ShouldResemble, map[string]interface{}{"ttfb": []interface{}{1e6}})
+		So(fields, ShouldNotContainKey, "Rate")
+		So(fields, ShouldNotContainKey, "converged")
+
+		var decoded BenchmarkResult
+		So(json.Unmarshal(data, &decoded), ShouldBeNil)
+		So(decoded, ShouldResemble, result)
+	})
+}
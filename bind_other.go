@@ -0,0 +1,65 @@
+//go:build !linux
+// +build !linux
+
+/*
+The MIT License (MIT)
+
+Copyright (c) 2014 David Johnston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package speedtest
+
+import (
+	"fmt"
+	"net"
+)
+
+// bindInterface configures the dialer to make connections from the given
+// network interface's address, as binding sockets to interfaces is not
+// supported.
+func bindInterface(dialer *net.Dialer, iface *net.Interface, network string) error {
+	ip, err := interfaceAddr(iface, network)
+	if err != nil {
+		return err
+	}
+	dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	return nil
+}
+
+// interfaceAddr returns the first address of the given interface that belongs
+// to the given network.
+func interfaceAddr(iface *net.Interface, network string) (net.IP, error) {
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	wantIPv6 := network == IPv6
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if isIPv6 := ipnet.IP.To4() == nil; isIPv6 == wantIPv6 {
+			return ipnet.IP, nil
+		}
+	}
+	return nil, fmt.Errorf("interface %v has no suitable address", iface.Name)
+}
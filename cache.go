@@ -0,0 +1,205 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2014 David Johnston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package speedtest
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Cache stores API responses on disk, so that they need not be fetched on
+// every run and remain available when the API is unreachable.
+type Cache struct {
+	// Dir is the directory cached responses are stored in.
+	Dir string
+	// TTL is how long a cached server list is used before being revalidated.
+	TTL time.Duration
+}
+
+// DefaultCacheDir returns the default cache directory, within the user's
+// cache directory ($XDG_CACHE_HOME on Linux).
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "speedtest"), nil
+}
+
+// StaleError is returned along with cached data when it could not be
+// revalidated.
+type StaleError struct {
+	// Err is the error that prevented revalidation.
+	Err error
+	// Age is the time since the cached data was last validated.
+	Age time.Duration
+}
+
+func (e *StaleError) Error() string {
+	return fmt.Sprintf("using cached data from %v ago: %v",
+		e.Age.Round(time.Minute), e.Err)
+}
+
+// cacheEntry is a cached response.
+type cacheEntry struct {
+	ETag         string
+	LastModified string
+	Validated    time.Time
+	Body         []byte
+}
+
+// FetchSettingsCached fetches the list of available servers, using the cache
+// if its copy is within its TTL. If the list cannot be fetched but is cached,
+// the cached list is returned along with a *StaleError.
+func (a API) FetchSettingsCached(c Cache) (Settings, error) {
+	settings := Settings{}
+	err := a.fetchCached(c, "servers", a.SettingsURL, c.TTL,
+		func(body []byte) error {
+			settings = Settings{}
+			if err := xml.Unmarshal(body, &settings); err != nil {
+				return err
+			}
+			if len(settings.Servers) == 0 {
+				return errors.New("server list is empty")
+			}
+			return nil
+		})
+	return settings, err
+}
+
+// FetchConfigCached fetches the recommended client configuration, which is
+// always revalidated as it depends on the client's location. If the
+// configuration cannot be fetched but is cached, the cached configuration is
+// returned along with a *StaleError.
+func (a API) FetchConfigCached(c Cache) (Config, error) {
+	config := Config{}
+	err := a.fetchCached(c, "config", a.ConfigURL, 0,
+		func(body []byte) error {
+			config = Config{}
+			return xml.Unmarshal(body, &config)
+		})
+	return config, err
+}
+
+// fetchCached fetches the given URL and parses its body, using the cached
+// copy of that URL if validated within the TTL, and otherwise
+// revalidating it. Fetched bodies are only cached if they parse. If the URL
+// cannot be fetched, any cached copy is parsed instead, and a *StaleError is
+// returned.
+func (a API) fetchCached(c Cache, name, url string, ttl time.Duration, parse func([]byte) error) error {
+	path := filepath.Join(c.Dir, cacheName(name, url))
+	entry, cached := loadCacheEntry(path)
+	if cached && time.Since(entry.Validated) < ttl {
+		return parse(entry.Body)
+	}
+
+	header := http.Header{}
+	if cached {
+		if entry.ETag != "" {
+			header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, body, err := a.fetch(url, header)
+	if err == nil {
+		switch {
+		case resp.StatusCode == http.StatusNotModified && cached:
+			err = parse(entry.Body)
+			entry.Validated = time.Now()
+		case resp.StatusCode == http.StatusOK:
+			if err = parse(body); err == nil {
+				entry = cacheEntry{
+					ETag:         resp.Header.Get("ETag"),
+					LastModified: resp.Header.Get("Last-Modified"),
+					Validated:    time.Now(),
+					Body:         body,
+				}
+			}
+		default:
+			err = fmt.Errorf("unexpected response: %v", resp.Status)
+		}
+	}
+
+	if err != nil {
+		if !cached {
+			return err
+		}
+		if perr := parse(entry.Body); perr != nil {
+			return perr
+		}
+		return &StaleError{err, time.Since(entry.Validated)}
+	}
+
+	// Failing to update the cache shouldn't prevent the data being used
+	saveCacheEntry(path, entry)
+	return nil
+}
+
+// cacheName returns the name of the file in which responses from the URL are
+// cached, so that each endpoint is cached separately.
+func cacheName(name, url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return fmt.Sprintf("%v-%x.json", name, sum[:8])
+}
+
+// loadCacheEntry reads the cache entry at the given path, if any.
+func loadCacheEntry(path string) (cacheEntry, bool) {
+	entry := cacheEntry{}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return entry, false
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return entry, false
+	}
+	return entry, true
+}
+
+// saveCacheEntry atomically writes the cache entry to the given path.
+func saveCacheEntry(path string, entry cacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,103 @@
+package speedtest
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+const testSettings = `<settings><servers>
+<server url="http://a.example/speedtest/upload.php" lat="51.5" lon="-0.1" name="London" country="United Kingdom" cc="GB" sponsor="Example" id="1" />
+</servers></settings>`
+
+func Test_FetchSettingsCached(t *testing.T) {
+	requests, validated := 0, 0
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				validated++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			io.WriteString(w, testSettings)
+		}))
+	defer ts.Close()
+
+	dir, _ := ioutil.TempDir("", "speedtest")
+	defer os.RemoveAll(dir)
+
+	api := NewAPI(http.Client{})
+	api.SettingsURL = ts.URL
+	cache := Cache{Dir: dir, TTL: time.Hour}
+
+	Convey("Settings should be fetched and cached", t, func() {
+		settings, err := api.FetchSettingsCached(cache)
+		So(err, ShouldBeNil)
+		So(len(settings.Servers), ShouldEqual, 1)
+		So(requests, ShouldEqual, 1)
+	})
+
+	Convey("Cached settings should be reused within the TTL", t, func() {
+		settings, err := api.FetchSettingsCached(cache)
+		So(err, ShouldBeNil)
+		So(len(settings.Servers), ShouldEqual, 1)
+		So(requests, ShouldEqual, 1)
+	})
+
+	Convey("Cached settings should be revalidated after the TTL", t, func() {
+		settings, err := api.FetchSettingsCached(Cache{Dir: dir})
+		So(err, ShouldBeNil)
+		So(len(settings.Servers), ShouldEqual, 1)
+		So(requests, ShouldEqual, 2)
+		So(validated, ShouldEqual, 1)
+	})
+
+	Convey("Cached settings should be used when the API is unreachable", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, testSettings)
+			}))
+		offline := api
+		offline.SettingsURL = ts.URL
+		_, err := offline.FetchSettingsCached(Cache{Dir: dir})
+		So(err, ShouldBeNil)
+		ts.Close()
+
+		settings, err := offline.FetchSettingsCached(Cache{Dir: dir})
+		So(len(settings.Servers), ShouldEqual, 1)
+		_, stale := err.(*StaleError)
+		So(stale, ShouldBeTrue)
+	})
+
+	Convey("Settings should be cached separately for each URL", t, func() {
+		other := api
+		other.SettingsURL = "http://127.0.0.1:1/"
+		_, err := other.FetchSettingsCached(cache)
+		So(err, ShouldNotBeNil)
+		_, stale := err.(*StaleError)
+		So(stale, ShouldBeFalse)
+
+		_, err = api.FetchSettingsCached(cache)
+		So(err, ShouldBeNil)
+		So(requests, ShouldEqual, 2)
+	})
+
+	Convey("Fetching should fail without a cached copy", t, func() {
+		empty, _ := ioutil.TempDir("", "speedtest")
+		defer os.RemoveAll(empty)
+
+		offline := api
+		offline.SettingsURL = "http://127.0.0.1:1/"
+		_, err := offline.FetchSettingsCached(Cache{Dir: empty})
+		So(err, ShouldNotBeNil)
+		_, stale := err.(*StaleError)
+		So(stale, ShouldBeFalse)
+	})
+}
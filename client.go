@@ -0,0 +1,168 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2014 David Johnston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package speedtest
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	// IPv4 restricts connections to IPv4 addresses.
+	IPv4 = "tcp4"
+	// IPv6 restricts connections to IPv6 addresses.
+	IPv6 = "tcp6"
+)
+
+// maxIdleConns is the number of idle connections kept open to each host.
+const maxIdleConns = 64
+
+const (
+	// HTTP1 forces requests to be made using HTTP/1.1.
+	HTTP1 = "HTTP/1.1"
+	// HTTP2 forces requests to be made using HTTP/2, which requires HTTPS.
+	HTTP2 = "HTTP/2.0"
+)
+
+// ClientOptions configures an HTTP client for accessing the API and test
+// servers.
+type ClientOptions struct {
+	// Timeout is the maximum time to wait for a connection to be
+	// established.
+	Timeout time.Duration
+	// Network restricts connections to an address family, being IPv4, IPv6
+	// or empty to use whichever the resolver returns.
+	Network string
+	// LocalAddr, if set, is the local IP address to make connections from.
+	LocalAddr string
+	// Interface, if set, is the name of the network interface to make
+	// connections from. On Linux, sockets are bound to the interface, which
+	// may require elevated privileges. Elsewhere, connections are made from
+	// the interface's first address of the appropriate family.
+	Interface string
+	// Proxy, if set, is the URL of a proxy to make all connections through.
+	// HTTP proxies ("http://host:port") are used by tunnelling connections
+	// with CONNECT, while SOCKS5 proxies ("socks5://host:port") are also
+	// supported. If unset, any proxy given by the environment is used.
+	Proxy *url.URL
+	// HTTPVersion, if set, forces the HTTP version used to HTTP1 or HTTP2.
+	// Otherwise, HTTP/2 is used where HTTPS servers support it. Note that
+	// HTTP/2 multiplexes concurrent requests over a single connection.
+	HTTPVersion string
+	// TLSConfig, if set, configures HTTPS connections.
+	TLSConfig *tls.Config
+}
+
+// NewClient creates an HTTP client configured by the given options.
+func NewClient(opts ClientOptions) (http.Client, error) {
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+
+	if opts.LocalAddr != "" {
+		ip := net.ParseIP(opts.LocalAddr)
+		if ip == nil {
+			return http.Client{}, fmt.Errorf("invalid source address %q",
+				opts.LocalAddr)
+		}
+		dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+
+	if opts.Interface != "" {
+		iface, err := net.InterfaceByName(opts.Interface)
+		if err != nil {
+			return http.Client{}, err
+		}
+		if err := bindInterface(dialer, iface, opts.Network); err != nil {
+			return http.Client{}, err
+		}
+	}
+
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if opts.Network != "" {
+			network = opts.Network
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+	transport := &http.Transport{
+		Proxy:       http.ProxyFromEnvironment,
+		DialContext: dial,
+		// Keep a connection open for each benchmark thread
+		MaxIdleConnsPerHost: maxIdleConns,
+	}
+	if opts.TLSConfig != nil {
+		// Cloned, as enabling HTTP/2 modifies the config
+		transport.TLSClientConfig = opts.TLSConfig.Clone()
+	}
+
+	if opts.Proxy != nil {
+		switch opts.Proxy.Scheme {
+		case "http":
+			transport.Proxy = nil
+			transport.DialContext = connectDialer(opts.Proxy, dial)
+		case "socks5", "socks5h":
+			transport.Proxy = http.ProxyURL(opts.Proxy)
+		default:
+			return http.Client{}, fmt.Errorf("unsupported proxy scheme %q",
+				opts.Proxy.Scheme)
+		}
+	}
+
+	switch opts.HTTPVersion {
+	case "":
+		transport.ForceAttemptHTTP2 = true
+	case HTTP1:
+		// A non-nil map disables HTTP/2
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	case HTTP2:
+		transport.ForceAttemptHTTP2 = true
+		return http.Client{Transport: http2Only{transport}}, nil
+	default:
+		return http.Client{}, fmt.Errorf("unsupported HTTP version %q",
+			opts.HTTPVersion)
+	}
+
+	return http.Client{Transport: transport}, nil
+}
+
+// http2Only is a RoundTripper that fails requests not made using HTTP/2.
+type http2Only struct {
+	http.RoundTripper
+}
+
+func (t http2Only) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.RoundTripper.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.ProtoMajor != 2 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%v does not support HTTP/2", req.URL.Host)
+	}
+	return resp, nil
+}
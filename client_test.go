@@ -0,0 +1,62 @@
+package speedtest
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_NewClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	Convey("Client should connect over the given network", t, func() {
+		client, err := NewClient(ClientOptions{Timeout: time.Second, Network: IPv4})
+		So(err, ShouldBeNil)
+		_, err = NewAPI(client).Fetch(ts.URL)
+		So(err, ShouldBeNil)
+	})
+
+	Convey("Client should not connect over other networks", t, func() {
+		client, err := NewClient(ClientOptions{Timeout: time.Second, Network: IPv6})
+		So(err, ShouldBeNil)
+		_, err = NewAPI(client).Fetch(ts.URL)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Client should keep a connection open for each benchmark thread", t, func() {
+		client, err := NewClient(ClientOptions{})
+		So(err, ShouldBeNil)
+		transport := client.Transport.(*http.Transport)
+		So(transport.MaxIdleConnsPerHost, ShouldEqual, maxIdleConns)
+	})
+}
+
+func Test_NewClientSource(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.RemoteAddr))
+		}))
+	defer ts.Close()
+
+	Convey("Client should connect from the given source address", t, func() {
+		client, err := NewClient(ClientOptions{LocalAddr: "127.0.0.1"})
+		So(err, ShouldBeNil)
+		body, err := NewAPI(client).Fetch(ts.URL)
+		So(err, ShouldBeNil)
+		So(string(body), ShouldStartWith, "127.0.0.1:")
+	})
+
+	Convey("Invalid source addresses should be rejected", t, func() {
+		_, err := NewClient(ClientOptions{LocalAddr: "localhost"})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Unknown interfaces should be rejected", t, func() {
+		_, err := NewClient(ClientOptions{Interface: "nonexistent0"})
+		So(err, ShouldNotBeNil)
+	})
+}
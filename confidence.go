@@ -0,0 +1,118 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2014 David Johnston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package speedtest
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+const (
+	// Confidence is the confidence level of the interval around a
+	// benchmark's estimated rate.
+	Confidence = 0.95
+	// bootstrapResamples is the number of resampled series from which a
+	// confidence interval is estimated.
+	bootstrapResamples = 1000
+)
+
+// BootstrapRate estimates a confidence interval at the given level (such as
+// 0.95) for the rate estimated from the given samples. One second blocks of
+// samples are resampled with replacement to preserve short-term correlation,
+// and the rate of each resampled series is estimated as by a benchmark. The
+// interval spans the central fraction of these estimates. Resampling is
+// seeded identically each time, so the interval is reproducible.
+func BootstrapRate(chunks []int, level float64) (low, high int) {
+	if len(chunks) <= windowSize {
+		rate := estimateRate(chunks)
+		return rate, rate
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	estimates := make([]int, bootstrapResamples)
+	series := make([]int, len(chunks))
+	for i := range estimates {
+		for j := 0; j < len(series); j += windowSize {
+			start := rng.Intn(len(chunks) - windowSize + 1)
+			copy(series[j:], chunks[start:start+windowSize])
+		}
+		estimates[i] = estimateRate(series)
+	}
+	sort.Ints(estimates)
+
+	tail := (1 - level) / 2
+	lowIdx := int(tail * float64(len(estimates)))
+	highIdx := int(math.Ceil((1-tail)*float64(len(estimates)))) - 1
+	if highIdx < lowIdx {
+		highIdx = lowIdx
+	}
+	return estimates[lowIdx], estimates[highIdx]
+}
+
+// Variation returns the coefficient of variation (standard deviation divided
+// by mean) of the rolling sums of size consecutive values, or 0 if there are
+// fewer values than size or their mean is 0.
+func Variation(data []int, size int) float64 {
+	if size <= 0 || len(data) < size {
+		return 0
+	}
+	sums := make([]float64, len(data)-size+1)
+	sum := 0
+	for i, n := range data {
+		sum += n
+		if i >= size {
+			sum -= data[i-size]
+		}
+		if i >= size-1 {
+			sums[i-size+1] = float64(sum)
+		}
+	}
+	return coefficientOfVariation(sums)
+}
+
+// coefficientOfVariation returns the population standard deviation of the
+// values divided by their mean, or 0 if their mean is 0.
+func coefficientOfVariation(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	if mean == 0 {
+		return 0
+	}
+
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	variance /= float64(len(values))
+
+	return math.Sqrt(variance) / mean
+}
@@ -0,0 +1,56 @@
+package speedtest
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"math/rand"
+	"testing"
+)
+
+func Test_BootstrapRate(t *testing.T) {
+	steady := make([]int, 100)
+	noisy := make([]int, 100)
+	r := rand.New(rand.NewSource(1))
+	for i := range steady {
+		steady[i] = 1000
+		noisy[i] = 500 + r.Intn(1000)
+	}
+
+	Convey("A constant rate should have no uncertainty", t, func() {
+		low, high := BootstrapRate(steady, Confidence)
+		So(low, ShouldEqual, 10000)
+		So(high, ShouldEqual, 10000)
+		So(Variation(steady, windowSize), ShouldEqual, 0)
+	})
+
+	Convey("A noisy rate should have an interval around its estimate", t, func() {
+		rate := estimateRate(noisy)
+		low, high := BootstrapRate(noisy, Confidence)
+		So(low, ShouldBeLessThan, high)
+		So(low, ShouldBeLessThanOrEqualTo, rate)
+		So(high, ShouldBeGreaterThanOrEqualTo, rate)
+		So(Variation(noisy, windowSize), ShouldBeGreaterThan, 0)
+
+		narrow, wide := BootstrapRate(noisy, 0.5)
+		So(narrow, ShouldBeGreaterThanOrEqualTo, low)
+		So(wide, ShouldBeLessThanOrEqualTo, high)
+	})
+
+	Convey("Intervals should be reproducible", t, func() {
+		low1, high1 := BootstrapRate(noisy, Confidence)
+		low2, high2 := BootstrapRate(noisy, Confidence)
+		So(low1, ShouldEqual, low2)
+		So(high1, ShouldEqual, high2)
+	})
+
+	Convey("Too few samples should give the estimate itself", t, func() {
+		low, high := BootstrapRate([]int{1, 2, 3}, Confidence)
+		So(low, ShouldEqual, estimateRate([]int{1, 2, 3}))
+		So(high, ShouldEqual, low)
+	})
+
+	Convey("Variation should be that of the rolling sums", t, func() {
+		// Rolling sums of size 2 are 2, 4, 6
+		So(Variation([]int{1, 1, 3, 3}, 2), ShouldAlmostEqual, 0.4082, 1e-4)
+		So(Variation([]int{1}, 2), ShouldEqual, 0)
+	})
+}
@@ -0,0 +1,76 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2014 David Johnston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package speedtest
+
+import "time"
+
+// Convergence describes when the rate measured by a benchmark is considered
+// stable enough for the benchmark to end early.
+type Convergence struct {
+	// Window is the period over which the rolling one second rate must be
+	// stable.
+	Window time.Duration `json:"window"`
+	// Threshold is the largest coefficient of variation (standard deviation
+	// divided by mean) of the rolling rate that is considered stable.
+	Threshold float64 `json:"threshold"`
+	// MinDuration is the shortest time the benchmark may run for.
+	MinDuration time.Duration `json:"minDuration"`
+}
+
+// DefaultConvergence ends a benchmark once its rate has varied by less than
+// 5% over two seconds, after running for at least three.
+var DefaultConvergence = Convergence{
+	Window:      2 * time.Second,
+	Threshold:   0.05,
+	MinDuration: 3 * time.Second,
+}
+
+// Converged reports whether the rolling one second sums of the given samples
+// have been stable for the last size sums.
+func Converged(data []int, size int, threshold float64) bool {
+	if len(data) < windowSize+size-1 {
+		return false
+	}
+
+	// Calculate most recent rolling sums
+	sums := make([]float64, size)
+	for i := range sums {
+		end := len(data) - size + 1 + i
+		for _, n := range data[end-windowSize : end] {
+			sums[i] += float64(n)
+		}
+	}
+
+	// A stalled transfer isn't a stable one
+	total := 0.0
+	for _, sum := range sums {
+		total += sum
+	}
+	if total == 0 {
+		return false
+	}
+
+	return coefficientOfVariation(sums) < threshold
+}
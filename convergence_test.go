@@ -0,0 +1,52 @@
+package speedtest
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func Test_Converged(t *testing.T) {
+	steady := make([]int, 30)
+	for i := range steady {
+		steady[i] = 100 + i%2
+	}
+	ramp := make([]int, 30)
+	for i := range ramp {
+		ramp[i] = i * 10
+	}
+
+	Convey("Steady samples should converge", t, func() {
+		So(Converged(steady, 10, 0.05), ShouldBeTrue)
+	})
+
+	Convey("Increasing samples should not converge", t, func() {
+		So(Converged(ramp, 10, 0.05), ShouldBeFalse)
+	})
+
+	Convey("Too few samples should not converge", t, func() {
+		So(Converged(steady[:15], 10, 0.05), ShouldBeFalse)
+	})
+}
+
+func Test_MeasureConvergence(t *testing.T) {
+	Convey("A steady benchmark should end early", t, func() {
+		b := fakeBenchmark{Chunk: 1000, Interval: 5 * time.Millisecond}
+		opts := BenchmarkOptions{
+			Threads:    1,
+			MaxThreads: 1,
+			Duration:   10 * time.Second,
+			Convergence: &Convergence{
+				Window:      time.Second,
+				Threshold:   0.2,
+				MinDuration: 2 * time.Second,
+			},
+		}
+
+		result := Measure(b, opts)
+		So(result.Converged, ShouldBeTrue)
+		So(result.Duration, ShouldBeLessThan, 5*time.Second)
+		So(len(result.Samples), ShouldBeLessThan, 50)
+		So(result.Rate, ShouldBeGreaterThan, 0)
+	})
+}
@@ -61,7 +61,7 @@ server reported by the service:
 		fmt.Println(NiceRate(rate))
 	}
 
-For a more detailed example, see speedtest-cli/cli.go
+For a more detailed example, see speedtest-cli/run.go
 
 The algorithms used by this package differs from that used by the original
 service. There are no guarantees about whether the approach used here is more
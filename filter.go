@@ -0,0 +1,81 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2014 David Johnston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package speedtest
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ServerFilter selects servers by their attributes. Fields left as their
+// zero value match all servers.
+type ServerFilter struct {
+	// CountryCode matches servers in the country with the given code,
+	// ignoring case.
+	CountryCode string
+	// Sponsor matches servers whose sponsor contains the given text,
+	// ignoring case.
+	Sponsor string
+	// Name matches servers whose name matches the expression.
+	Name *regexp.Regexp
+	// MaxDistance matches servers within the given distance, in km.
+	MaxDistance float64
+	// ExcludeIDs matches servers without any of the given IDs.
+	ExcludeIDs []int
+}
+
+// Match returns true if the server matches the filter.
+func (f ServerFilter) Match(s Server) bool {
+	if f.CountryCode != "" && !strings.EqualFold(s.CountryCode, f.CountryCode) {
+		return false
+	}
+	if f.Sponsor != "" && !strings.Contains(
+		strings.ToLower(s.Sponsor), strings.ToLower(f.Sponsor)) {
+		return false
+	}
+	if f.Name != nil && !f.Name.MatchString(s.Name) {
+		return false
+	}
+	if f.MaxDistance > 0 && s.Distance > f.MaxDistance {
+		return false
+	}
+	for _, id := range f.ExcludeIDs {
+		if s.ID == id {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter returns the servers that match the filter.
+func (s Servers) Filter(f ServerFilter) Servers {
+	var matched Servers
+	for _, server := range s {
+		if f.Match(server) {
+			matched = append(matched, server)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,43 @@
+package speedtest
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"regexp"
+	"testing"
+)
+
+func Test_ServerFilter(t *testing.T) {
+	servers := Servers{
+		{ID: 1, CountryCode: "GB", Sponsor: "Vodafone UK", Name: "London", Distance: 10},
+		{ID: 2, CountryCode: "GB", Sponsor: "BT", Name: "Manchester", Distance: 250},
+		{ID: 3, CountryCode: "DE", Sponsor: "Vodafone DE", Name: "Berlin", Distance: 900},
+		{ID: 4, CountryCode: "gb", Sponsor: "vodafone", Name: "Leeds", Distance: 150},
+	}
+
+	ids := func(s Servers) []int {
+		var ids []int
+		for _, server := range s {
+			ids = append(ids, server.ID)
+		}
+		return ids
+	}
+
+	Convey("An empty filter should match all servers", t, func() {
+		So(ids(servers.Filter(ServerFilter{})), ShouldResemble, []int{1, 2, 3, 4})
+	})
+
+	Convey("Filters should match country and sponsor ignoring case", t, func() {
+		f := ServerFilter{CountryCode: "GB", Sponsor: "VODAFONE"}
+		So(ids(servers.Filter(f)), ShouldResemble, []int{1, 4})
+	})
+
+	Convey("Filters should match name, distance and excluded IDs", t, func() {
+		So(ids(servers.Filter(ServerFilter{
+			Name: regexp.MustCompile("^L"),
+		})), ShouldResemble, []int{1, 4})
+		So(ids(servers.Filter(ServerFilter{
+			MaxDistance: 200,
+			ExcludeIDs:  []int{1},
+		})), ShouldResemble, []int{4})
+	})
+}
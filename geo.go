@@ -0,0 +1,107 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2014 David Johnston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package speedtest
+
+import (
+	"math"
+)
+
+const (
+	// WGS-84 ellipsoid parameters, in km
+	wgs84A = 6378.137
+	wgs84F = 1 / 298.257223563
+	wgs84B = wgs84A * (1 - wgs84F)
+	// vincentyIterations limits the iterations made by Vincenty before it
+	// gives up, which happens for nearly antipodal points.
+	vincentyIterations = 200
+	vincentyTolerance  = 1e-12
+)
+
+// DistanceFunc calculates the distance in km between two geographical
+// positions given in degrees.
+type DistanceFunc func(lat1, lon1, lat2, lon2 float64) float64
+
+// DistanceDegrees calculates the great-circle distance in km between two
+// geographical positions given in degrees, using the haversine formula.
+func DistanceDegrees(lat1, lon1, lat2, lon2 float64) float64 {
+	return Distance(lat1*degToRad, lon1*degToRad, lat2*degToRad, lon2*degToRad)
+}
+
+// VincentyDistance calculates the distance in km between two geographical
+// positions given in degrees on the WGS-84 ellipsoid, using Vincenty's
+// inverse formula. It is accurate to within a millimetre, but is slower than
+// DistanceDegrees, to which it falls back for nearly antipodal points where
+// the formula fails to converge.
+func VincentyDistance(lat1, lon1, lat2, lon2 float64) float64 {
+	// Reduced latitudes
+	u1 := math.Atan((1 - wgs84F) * math.Tan(lat1*degToRad))
+	u2 := math.Atan((1 - wgs84F) * math.Tan(lat2*degToRad))
+	sinU1, cosU1 := math.Sincos(u1)
+	sinU2, cosU2 := math.Sincos(u2)
+
+	l := (lon2 - lon1) * degToRad
+	lambda := l
+	var sinSigma, cosSigma, sigma, cos2Alpha, cos2SigmaM float64
+	for i := 0; ; i++ {
+		if i == vincentyIterations {
+			return DistanceDegrees(lat1, lon1, lat2, lon2)
+		}
+		sinLambda, cosLambda := math.Sincos(lambda)
+		sinSigma = math.Hypot(cosU2*sinLambda,
+			cosU1*sinU2-sinU1*cosU2*cosLambda)
+		if sinSigma == 0 {
+			// Coincident points
+			return 0
+		}
+		cosSigma = sinU1*sinU2 + cosU1*cosU2*cosLambda
+		sigma = math.Atan2(sinSigma, cosSigma)
+		sinAlpha := cosU1 * cosU2 * sinLambda / sinSigma
+		cos2Alpha = 1 - sinAlpha*sinAlpha
+		cos2SigmaM = 0
+		if cos2Alpha != 0 {
+			// Not an equatorial line
+			cos2SigmaM = cosSigma - 2*sinU1*sinU2/cos2Alpha
+		}
+		c := wgs84F / 16 * cos2Alpha * (4 + wgs84F*(4-3*cos2Alpha))
+		prev := lambda
+		lambda = l + (1-c)*wgs84F*sinAlpha*(sigma+c*sinSigma*
+			(cos2SigmaM+c*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+		if math.Abs(lambda) > math.Pi {
+			// Diverging, as happens for nearly antipodal points
+			return DistanceDegrees(lat1, lon1, lat2, lon2)
+		}
+		if math.Abs(lambda-prev) < vincentyTolerance {
+			break
+		}
+	}
+
+	uSq := cos2Alpha * (wgs84A*wgs84A - wgs84B*wgs84B) / (wgs84B * wgs84B)
+	a := 1 + uSq/16384*(4096+uSq*(-768+uSq*(320-175*uSq)))
+	b := uSq / 1024 * (256 + uSq*(-128+uSq*(74-47*uSq)))
+	deltaSigma := b * sinSigma * (cos2SigmaM + b/4*(cosSigma*
+		(-1+2*cos2SigmaM*cos2SigmaM)-b/6*cos2SigmaM*
+		(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+	return wgs84B * a * (sigma - deltaSigma)
+}
@@ -0,0 +1,82 @@
+package speedtest
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"math"
+	"testing"
+	"testing/quick"
+)
+
+// position generates a random latitude and longitude in degrees.
+func position(lat, lon float64) (float64, float64) {
+	return math.Mod(lat, 90), math.Mod(lon, 180)
+}
+
+func Test_Distance(t *testing.T) {
+	halfCircumference := math.Pi * earthRadius
+
+	for name, distance := range map[string]DistanceFunc{
+		"Haversine": DistanceDegrees,
+		"Vincenty":  VincentyDistance,
+	} {
+		Convey(name+" distance between identical points should be zero", t, func() {
+			So(quick.Check(func(lat, lon float64) bool {
+				lat, lon = position(lat, lon)
+				return distance(lat, lon, lat, lon) == 0
+			}, nil), ShouldBeNil)
+			So(distance(51.5074, -0.1278, 51.5074, -0.1278), ShouldEqual, 0)
+			So(distance(90, 0, 90, 0), ShouldEqual, 0)
+		})
+
+		Convey(name+" distance should be symmetric and bounded", t, func() {
+			So(quick.Check(func(lat1, lon1, lat2, lon2 float64) bool {
+				lat1, lon1 = position(lat1, lon1)
+				lat2, lon2 = position(lat2, lon2)
+				d := distance(lat1, lon1, lat2, lon2)
+				return d >= 0 && d <= halfCircumference*1.01 &&
+					math.Abs(d-distance(lat2, lon2, lat1, lon1)) < 1e-6
+			}, nil), ShouldBeNil)
+		})
+
+		Convey(name+" distance between antipodal points should be half the circumference", t, func() {
+			So(quick.Check(func(lat, lon float64) bool {
+				lat, lon = position(lat, lon)
+				d := distance(lat, lon, -lat, lon+180)
+				return !math.IsNaN(d) && math.Abs(d-halfCircumference) < 50
+			}, nil), ShouldBeNil)
+		})
+
+		Convey(name+" distance from a pole should not depend on longitude", t, func() {
+			So(quick.Check(func(lat, lon1, lon2 float64) bool {
+				lat, lon1 = position(lat, lon1)
+				_, lon2 = position(0, lon2)
+				return math.Abs(distance(90, lon1, lat, 0)-
+					distance(90, lon2, lat, 0)) < 1e-6
+			}, nil), ShouldBeNil)
+			So(distance(90, 0, -90, 0), ShouldAlmostEqual, halfCircumference, 15)
+		})
+	}
+
+	Convey("Haversine distance should be accurate for short distances", t, func() {
+		// One metre north along a meridian
+		So(DistanceDegrees(51.5, 0, 51.5+1.0/111195, 0), ShouldAlmostEqual, 0.001, 1e-6)
+		So(Distance(0, 0, 0, math.Pi), ShouldAlmostEqual, halfCircumference, 1e-9)
+	})
+
+	Convey("Vincenty distance should match the WGS-84 ellipsoid", t, func() {
+		// One degree along the equator
+		So(VincentyDistance(0, 0, 0, 1), ShouldAlmostEqual, 111.319491, 1e-6)
+		// Meridian from pole to pole
+		So(VincentyDistance(90, 0, -90, 0), ShouldAlmostEqual, 20003.931, 1e-3)
+		// London to New York
+		So(VincentyDistance(51.5074, -0.1278, 40.7128, -74.0060), ShouldAlmostEqual, 5585, 5)
+	})
+
+	Convey("Distances should be updated for all servers", t, func() {
+		settings := Settings{Servers: Servers{{Lat: 51.5, Lon: -0.13}}}
+		settings.UpdateDistances(51.5, -0.13)
+		So(settings.Servers[0].Distance, ShouldEqual, 0)
+		settings.UpdateDistancesWith(40.7128, -74.0060, VincentyDistance)
+		So(settings.Servers[0].Distance, ShouldAlmostEqual, 5585, 5)
+	})
+}
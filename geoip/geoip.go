@@ -0,0 +1,76 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2014 David Johnston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package geoip locates speedtest clients using a MaxMind-format database,
+// kept apart from the speedtest package so that its users don't depend on
+// the database reader.
+package geoip
+
+import (
+	"fmt"
+	"github.com/johnsto/speedtest"
+	"github.com/oschwald/maxminddb-golang"
+	"net"
+)
+
+// record holds the fields read from a MaxMind-format city database.
+type record struct {
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Location struct {
+		Latitude  *float64 `maxminddb:"latitude"`
+		Longitude *float64 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+}
+
+// Lookup finds the location of the IP address in the MaxMind-format database
+// (such as GeoLite2-City.mmdb) at the given path.
+func Lookup(path string, ip net.IP) (speedtest.Location, error) {
+	if ip == nil {
+		return speedtest.Location{}, fmt.Errorf("no IP address to look up")
+	}
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		return speedtest.Location{}, err
+	}
+	defer db.Close()
+
+	var rec record
+	if err := db.Lookup(ip, &rec); err != nil {
+		return speedtest.Location{}, err
+	}
+	if rec.Location.Latitude == nil || rec.Location.Longitude == nil {
+		return speedtest.Location{}, fmt.Errorf("no location for %v", ip)
+	}
+	return speedtest.Location{
+		Name:        rec.City.Names["en"],
+		CountryCode: rec.Country.ISOCode,
+		Lat:         *rec.Location.Latitude,
+		Lon:         *rec.Location.Longitude,
+	}, nil
+}
@@ -0,0 +1,129 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"math"
+	"net"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// mmdbValue appends the MaxMind DB encoding of a string, float64, uint or
+// map to buf.
+func mmdbValue(buf *bytes.Buffer, v interface{}) {
+	control := func(kind byte, size int) {
+		if kind > 7 {
+			buf.WriteByte(byte(size))
+			buf.WriteByte(kind - 7)
+		} else {
+			buf.WriteByte(kind<<5 | byte(size))
+		}
+	}
+	switch v := v.(type) {
+	case string:
+		control(2, len(v))
+		buf.WriteString(v)
+	case float64:
+		control(3, 8)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(v))
+	case uint:
+		control(6, 4)
+		binary.Write(buf, binary.BigEndian, uint32(v))
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		control(7, len(v))
+		for _, key := range keys {
+			mmdbValue(buf, key)
+			mmdbValue(buf, v[key])
+		}
+	}
+}
+
+// writeDB writes an IPv4 MaxMind DB holding a single record for the given
+// network, returning its path.
+func writeDB(t *testing.T, network string, data map[string]interface{}) string {
+	_, ipnet, err := net.ParseCIDR(network)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix, _ := ipnet.Mask.Size()
+	ip := ipnet.IP.To4()
+
+	// One node per bit of the prefix, each pointing to the next node (or,
+	// for the last, the data) on the side of the bit, and to the empty
+	// record (equal to the node count) on the other.
+	nodes := uint32(prefix)
+	var db bytes.Buffer
+	for i := 0; i < prefix; i++ {
+		next := uint32(i + 1)
+		if i == prefix-1 {
+			next = nodes + 16
+		}
+		records := [2]uint32{nodes, nodes}
+		records[ip[i/8]>>(7-uint(i%8))&1] = next
+		for _, r := range records {
+			db.Write([]byte{byte(r >> 16), byte(r >> 8), byte(r)})
+		}
+	}
+	db.Write(make([]byte, 16))
+	mmdbValue(&db, data)
+
+	db.WriteString("\xab\xcd\xefMaxMind.com")
+	mmdbValue(&db, map[string]interface{}{
+		"binary_format_major_version": uint(2),
+		"binary_format_minor_version": uint(0),
+		"database_type":               "Test-City",
+		"ip_version":                  uint(4),
+		"node_count":                  uint(nodes),
+		"record_size":                 uint(24),
+	})
+
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	if err := ioutil.WriteFile(path, db.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_Lookup(t *testing.T) {
+	path := writeDB(t, "81.2.69.0/24", map[string]interface{}{
+		"city":     map[string]interface{}{"names": map[string]interface{}{"en": "London"}},
+		"country":  map[string]interface{}{"iso_code": "GB"},
+		"location": map[string]interface{}{"latitude": 51.5, "longitude": -0.13},
+	})
+
+	Convey("Addresses in the database should be located", t, func() {
+		loc, err := Lookup(path, net.ParseIP("81.2.69.160"))
+		So(err, ShouldBeNil)
+		So(loc.Name, ShouldEqual, "London")
+		So(loc.CountryCode, ShouldEqual, "GB")
+		So(loc.Lat, ShouldEqual, 51.5)
+		So(loc.Lon, ShouldEqual, -0.13)
+	})
+
+	Convey("Addresses without a location should be rejected", t, func() {
+		_, err := Lookup(path, net.ParseIP("192.0.2.1"))
+		So(err, ShouldNotBeNil)
+		_, err = Lookup(path, nil)
+		So(err, ShouldNotBeNil)
+
+		noLocation := writeDB(t, "192.0.2.0/24", map[string]interface{}{
+			"country": map[string]interface{}{"iso_code": "GB"},
+		})
+		_, err = Lookup(noLocation, net.ParseIP("192.0.2.1"))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Lookups should fail without a database", t, func() {
+		_, err := Lookup("/nonexistent.mmdb", net.ParseIP("192.0.2.1"))
+		So(err, ShouldNotBeNil)
+	})
+}
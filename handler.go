@@ -0,0 +1,75 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2014 David Johnston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package speedtest
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+)
+
+// downloadSize is the size of the file served for download benchmarks,
+// matching that of random1000x1000.jpg on speedtest.net servers.
+const downloadSize = 1986284
+
+// Handler is an http.Handler that stands in for a speedtest.net test server,
+// serving the files used by benchmarks and latency probes beneath
+// /speedtest/. It is useful for testing against a known local server.
+type Handler struct{}
+
+// NewHandler creates a new test server handler.
+func NewHandler() Handler {
+	return Handler{}
+}
+
+// ServeHTTP serves latency probes, download files and uploads.
+func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/speedtest/latency.txt":
+		io.WriteString(w, "test=test\n")
+	case "/speedtest/random1000x1000.jpg":
+		reader := NewJunkReader(downloadSize)
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Header().Set("Content-Length", strconv.Itoa(downloadSize))
+		io.Copy(w, &reader)
+	case "/speedtest/upload.php":
+		n, _ := io.Copy(ioutil.Discard, r.Body)
+		fmt.Fprintf(w, "size=%d", n)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// LocalServer returns a definition of a Handler served at the given base
+// URL, such as "https://localhost:8443".
+func LocalServer(baseURL string) Server {
+	return Server{
+		URL:     baseURL + "/speedtest/upload.php",
+		Name:    "Local",
+		Sponsor: "Local",
+	}
+}
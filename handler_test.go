@@ -0,0 +1,69 @@
+package speedtest
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_Handler(t *testing.T) {
+	ts := httptest.NewUnstartedServer(NewHandler())
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	// Trust the server's self-signed certificate
+	roots := x509.NewCertPool()
+	roots.AddCert(ts.Certificate())
+	tlsConfig := &tls.Config{RootCAs: roots}
+
+	server := LocalServer(ts.URL)
+	opts := BenchmarkOptions{
+		Threads:    2,
+		MaxThreads: 2,
+		Duration:   time.Second,
+	}
+
+	Convey("Benchmarks should negotiate HTTP/2 over HTTPS", t, func() {
+		client, err := NewClient(ClientOptions{TLSConfig: tlsConfig})
+		So(err, ShouldBeNil)
+
+		result := Measure(NewDownloadBenchmark(client, server), opts)
+		So(result.Rate, ShouldBeGreaterThan, 0)
+		So(result.Protocol, ShouldEqual, HTTP2)
+		So(result.TLSVersion, ShouldStartWith, "TLS 1.")
+		So(len(result.Timings.TLS), ShouldBeGreaterThan, 0)
+	})
+
+	Convey("Benchmarks should use HTTP/1.1 if forced", t, func() {
+		client, err := NewClient(ClientOptions{
+			TLSConfig:   tlsConfig,
+			HTTPVersion: HTTP1,
+		})
+		So(err, ShouldBeNil)
+
+		result := Measure(NewUploadBenchmark(client, server), opts)
+		So(result.Rate, ShouldBeGreaterThan, 0)
+		So(result.Protocol, ShouldEqual, HTTP1)
+		So(result.TLSVersion, ShouldStartWith, "TLS 1.")
+	})
+
+	Convey("Latency should be measurable over HTTPS", t, func() {
+		client, _ := NewClient(ClientOptions{TLSConfig: tlsConfig})
+		latencies, err := MeasureLatency(NewPinger(client, server), 3)
+		So(err, ShouldBeNil)
+		So(len(latencies), ShouldEqual, 3)
+	})
+
+	Convey("Forcing HTTP/2 should fail without HTTPS", t, func() {
+		plain := httptest.NewServer(NewHandler())
+		defer plain.Close()
+
+		client, _ := NewClient(ClientOptions{HTTPVersion: HTTP2})
+		_, err := NewPinger(client, LocalServer(plain.URL)).Ping()
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,203 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2014 David Johnston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package speedtest
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+)
+
+// ServerIndex is a k-d tree of servers for fast nearest and within-radius
+// queries, useful when many locations are compared against the same list
+// of servers. Servers are indexed by their position on the unit sphere, on
+// which straight-line (chord) distance increases with great-circle distance.
+// For a single lookup, sorting servers after UpdateDistances is cheaper than
+// building an index.
+type ServerIndex struct {
+	points []indexPoint
+}
+
+// indexPoint is a server's position on the unit sphere. The points of a
+// ServerIndex are ordered so that each range has its median, split along
+// axis depth%3, at its centre.
+type indexPoint struct {
+	v      [3]float64
+	server Server
+}
+
+// NewServerIndex builds an index of copies of the given servers, so it is
+// unaffected by later changes to the slice.
+func NewServerIndex(servers Servers) *ServerIndex {
+	idx := &ServerIndex{
+		points: make([]indexPoint, len(servers)),
+	}
+	for i, s := range servers {
+		idx.points[i] = indexPoint{unitVector(s.Lat, s.Lon), s}
+	}
+	idx.build(idx.points, 0)
+	return idx
+}
+
+// Len returns the number of indexed servers.
+func (idx *ServerIndex) Len() int { return len(idx.points) }
+
+// build arranges points into a k-d tree rooted at its median.
+func (idx *ServerIndex) build(points []indexPoint, depth int) {
+	if len(points) <= 1 {
+		return
+	}
+	axis := depth % 3
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].v[axis] < points[j].v[axis]
+	})
+	mid := len(points) / 2
+	idx.build(points[:mid], depth+1)
+	idx.build(points[mid+1:], depth+1)
+}
+
+// Nearest returns up to k servers nearest to the given position in degrees,
+// nearest first, with their Distance set.
+func (idx *ServerIndex) Nearest(lat, lon float64, k int) Servers {
+	if k <= 0 {
+		return nil
+	}
+	q := unitVector(lat, lon)
+	h := &neighbours{}
+	idx.nearest(idx.points, 0, q, k, h)
+	found := make([]indexPoint, h.Len())
+	for i := len(found) - 1; i >= 0; i-- {
+		found[i] = heap.Pop(h).(neighbour).point
+	}
+	return idx.result(found, lat, lon)
+}
+
+func (idx *ServerIndex) nearest(points []indexPoint, depth int, q [3]float64, k int, h *neighbours) {
+	if len(points) == 0 {
+		return
+	}
+	mid := len(points) / 2
+	p := points[mid]
+	if d := chord2(p.v, q); h.Len() < k {
+		heap.Push(h, neighbour{p, d})
+	} else if d < (*h)[0].dist {
+		(*h)[0] = neighbour{p, d}
+		heap.Fix(h, 0)
+	}
+
+	axis := depth % 3
+	diff := q[axis] - p.v[axis]
+	near, far := points[:mid], points[mid+1:]
+	if diff > 0 {
+		near, far = far, near
+	}
+	idx.nearest(near, depth+1, q, k, h)
+	if h.Len() < k || diff*diff < (*h)[0].dist {
+		idx.nearest(far, depth+1, q, k, h)
+	}
+}
+
+// Within returns the servers within radius km of the given position in
+// degrees, nearest first, with their Distance set.
+func (idx *ServerIndex) Within(lat, lon, radius float64) Servers {
+	if radius < 0 {
+		return nil
+	}
+	// Convert the great-circle radius to a squared chord length
+	limit := 4.0
+	if angle := radius / earthRadius; angle < math.Pi {
+		c := 2 * math.Sin(angle/2)
+		limit = c * c
+	}
+	q := unitVector(lat, lon)
+	var found []indexPoint
+	idx.within(idx.points, 0, q, limit, &found)
+	servers := idx.result(found, lat, lon)
+	servers.SortByDistance()
+	return servers
+}
+
+func (idx *ServerIndex) within(points []indexPoint, depth int, q [3]float64, limit float64, found *[]indexPoint) {
+	if len(points) == 0 {
+		return
+	}
+	mid := len(points) / 2
+	p := points[mid]
+	if chord2(p.v, q) <= limit {
+		*found = append(*found, p)
+	}
+	axis := depth % 3
+	diff := q[axis] - p.v[axis]
+	if diff <= 0 || diff*diff <= limit {
+		idx.within(points[:mid], depth+1, q, limit, found)
+	}
+	if diff >= 0 || diff*diff <= limit {
+		idx.within(points[mid+1:], depth+1, q, limit, found)
+	}
+}
+
+// result copies the servers at the given points, setting their distance
+// from the given position.
+func (idx *ServerIndex) result(points []indexPoint, lat, lon float64) Servers {
+	servers := make(Servers, len(points))
+	for i, p := range points {
+		servers[i] = p.server
+		servers[i].Distance = DistanceDegrees(servers[i].Lat, servers[i].Lon, lat, lon)
+	}
+	return servers
+}
+
+// unitVector converts a position in degrees to a point on the unit sphere.
+func unitVector(lat, lon float64) [3]float64 {
+	sinLat, cosLat := math.Sincos(lat * degToRad)
+	sinLon, cosLon := math.Sincos(lon * degToRad)
+	return [3]float64{cosLat * cosLon, cosLat * sinLon, sinLat}
+}
+
+// chord2 returns the squared straight-line distance between two points.
+func chord2(a, b [3]float64) float64 {
+	x, y, z := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return x*x + y*y + z*z
+}
+
+// neighbour is a candidate result of a nearest query.
+type neighbour struct {
+	point indexPoint
+	dist  float64
+}
+
+// neighbours is a max-heap of candidates, farthest first.
+type neighbours []neighbour
+
+func (h neighbours) Len() int            { return len(h) }
+func (h neighbours) Less(i, j int) bool  { return h[i].dist > h[j].dist }
+func (h neighbours) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *neighbours) Push(x interface{}) { *h = append(*h, x.(neighbour)) }
+func (h *neighbours) Pop() interface{} {
+	old := *h
+	n := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return n
+}
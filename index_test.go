@@ -0,0 +1,124 @@
+package speedtest
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"math/rand"
+	"testing"
+)
+
+// randomServers generates servers at random positions.
+func randomServers(r *rand.Rand, n int) Servers {
+	servers := make(Servers, n)
+	for i := range servers {
+		servers[i] = Server{
+			ID:  i,
+			Lat: r.Float64()*180 - 90,
+			Lon: r.Float64()*360 - 180,
+		}
+	}
+	return servers
+}
+
+// nearestBySort finds the nearest servers by sorting every server.
+func nearestBySort(servers Servers, lat, lon float64, k int) Servers {
+	sorted := make(Servers, len(servers))
+	copy(sorted, servers)
+	Settings{Servers: sorted}.UpdateDistances(lat, lon)
+	sorted.SortByDistance()
+	if k > len(sorted) {
+		k = len(sorted)
+	}
+	return sorted[:k]
+}
+
+func distances(s Servers) []float64 {
+	d := make([]float64, len(s))
+	for i, server := range s {
+		d[i] = server.Distance
+	}
+	return d
+}
+
+func Test_ServerIndex(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	servers := randomServers(r, 2000)
+	idx := NewServerIndex(servers)
+
+	Convey("Nearest servers should match sorting every server", t, func() {
+		for i := 0; i < 100; i++ {
+			lat, lon := r.Float64()*180-90, r.Float64()*360-180
+			So(distances(idx.Nearest(lat, lon, 5)), ShouldResemble,
+				distances(nearestBySort(servers, lat, lon, 5)))
+		}
+	})
+
+	Convey("Servers within a radius should match sorting every server", t, func() {
+		for i := 0; i < 100; i++ {
+			lat, lon := r.Float64()*180-90, r.Float64()*360-180
+			var expected Servers
+			for _, s := range nearestBySort(servers, lat, lon, len(servers)) {
+				if s.Distance <= 1000 {
+					expected = append(expected, s)
+				}
+			}
+			So(distances(idx.Within(lat, lon, 1000)), ShouldResemble,
+				distances(expected))
+		}
+	})
+
+	Convey("Queries should handle edge cases", t, func() {
+		So(idx.Nearest(0, 0, 0), ShouldBeEmpty)
+		So(idx.Nearest(0, 0, 5000), ShouldHaveLength, len(servers))
+		So(idx.Within(0, 0, -1), ShouldBeEmpty)
+		So(idx.Within(0, 0, 30000), ShouldHaveLength, len(servers))
+		So(NewServerIndex(nil).Nearest(0, 0, 1), ShouldBeEmpty)
+
+		s := Servers{{ID: 7, Lat: 51.5, Lon: -0.13}}
+		nearest := NewServerIndex(s).Nearest(51.5, -0.13, 1)
+		So(nearest[0].ID, ShouldEqual, 7)
+		So(nearest[0].Distance, ShouldEqual, 0)
+		So(s[0].Distance, ShouldEqual, 0)
+	})
+
+	Convey("Reordering the indexed servers should not affect queries", t, func() {
+		s := randomServers(r, 200)
+		original := append(Servers(nil), s...)
+		idx := NewServerIndex(s)
+		Settings{Servers: s}.UpdateDistances(0, 0)
+		s.SortByDistance()
+		for i := 0; i < 20; i++ {
+			lat, lon := r.Float64()*180-90, r.Float64()*360-180
+			So(idx.Nearest(lat, lon, 3), ShouldResemble,
+				nearestBySort(original, lat, lon, 3))
+		}
+	})
+}
+
+func benchmarkNearest(b *testing.B, nearest func(lat, lon float64) Servers) {
+	r := rand.New(rand.NewSource(1))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		nearest(r.Float64()*180-90, r.Float64()*360-180)
+	}
+}
+
+func BenchmarkNearestSort(b *testing.B) {
+	servers := randomServers(rand.New(rand.NewSource(1)), 10000)
+	benchmarkNearest(b, func(lat, lon float64) Servers {
+		return nearestBySort(servers, lat, lon, 5)
+	})
+}
+
+func BenchmarkNearestIndex(b *testing.B) {
+	idx := NewServerIndex(randomServers(rand.New(rand.NewSource(1)), 10000))
+	benchmarkNearest(b, func(lat, lon float64) Servers {
+		return idx.Nearest(lat, lon, 5)
+	})
+}
+
+func BenchmarkWithinIndex(b *testing.B) {
+	idx := NewServerIndex(randomServers(rand.New(rand.NewSource(1)), 10000))
+	benchmarkNearest(b, func(lat, lon float64) Servers {
+		return idx.Within(lat, lon, 500)
+	})
+}
@@ -26,6 +26,8 @@ package speedtest
 
 import "io"
 import "fmt"
+import "strconv"
+import "strings"
 
 // A JunkReader produces junk-ish data
 type JunkReader struct {
@@ -78,18 +80,51 @@ func (b CallbackWriter) Write(p []byte) (n int, err error) {
 	return len(p), b.Callback(len(p))
 }
 
-// NiceRate represents a value measured in bytes/sec as bps, kbps or mbps as
-// appropriate.
+// NiceRate represents a value measured in bytes/sec in SI bits/sec, such as
+// "94.21Mbps". See Rate for other units.
 func NiceRate(rate int) string {
-	bps := float64(8 * rate)
-	kbps := bps / 1024
-	mbps := kbps / 1024
-
-	if mbps > 0.1 {
-		return fmt.Sprintf("%.2fmbps", mbps)
-	} else if kbps > 0.1 {
-		return fmt.Sprintf("%.2fkbps", kbps)
-	} else {
-		return fmt.Sprintf("%.2fbps", bps)
+	return Rate(rate).String()
+}
+
+// NiceRateMargin represents a value measured in bytes/sec along with its
+// margin of error, such as "94.21Mbps ±3.10", in the units chosen by
+// NiceRate.
+func NiceRateMargin(rate int, margin int) string {
+	return Rate(rate).FormatMargin(Rate(margin), DefaultRateFormat)
+}
+
+// byteUnits maps (lowercase) unit suffixes to their size in bytes.
+var byteUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+// ParseBytes parses a quantity of data such as "500", "50MB" or "1.5GiB" into
+// a number of bytes. SI units are powers of 1000 and IEC units are powers of
+// 1024.
+func ParseBytes(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(s)
+	}
+	value, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid quantity %q", s)
+	}
+	unit, ok := byteUnits[strings.ToLower(strings.TrimSpace(s[i:]))]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit in quantity %q", s)
 	}
+	return int64(value * unit), nil
 }
@@ -37,3 +37,36 @@ func Test_CallbackWriter(t *testing.T) {
 		So(err, ShouldEqual, io.EOF)
 	})
 }
+
+func Test_ParseBytes(t *testing.T) {
+	Convey("ParseBytes should understand SI and IEC units", t, func() {
+		n, err := ParseBytes("500")
+		So(n, ShouldEqual, 500)
+		So(err, ShouldBeNil)
+
+		n, _ = ParseBytes("50MB")
+		So(n, ShouldEqual, 50000000)
+
+		n, _ = ParseBytes("1.5 GiB")
+		So(n, ShouldEqual, 1610612736)
+
+		n, _ = ParseBytes("2kb")
+		So(n, ShouldEqual, 2000)
+	})
+
+	Convey("ParseBytes should reject invalid quantities", t, func() {
+		_, err := ParseBytes("MB")
+		So(err, ShouldNotBeNil)
+
+		_, err = ParseBytes("10 parsecs")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func Test_NiceRateMargin(t *testing.T) {
+	Convey("Margins should be given in the units of the rate", t, func() {
+		So(NiceRate(1250000), ShouldEqual, "10.00Mbps")
+		So(NiceRateMargin(1250000, 125000), ShouldEqual, "10.00Mbps ±1.00")
+		So(NiceRateMargin(1250, 125), ShouldEqual, "10.00kbps ±1.00")
+	})
+}
@@ -0,0 +1,168 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2014 David Johnston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package speedtest
+
+import (
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// IdlePings is the number of latency probes used to establish the idle
+	// latency of a line.
+	IdlePings = 5
+	// PingTimeout is the time allowed for a latency probe made by a pinger
+	// created with NewPinger.
+	PingTimeout = 5 * time.Second
+	// pingInterval is the default delay between latency probes made while a
+	// benchmark is running.
+	pingInterval = 200 * time.Millisecond
+)
+
+// A Pinger measures the round trip time to a server.
+type Pinger interface {
+	Ping() (time.Duration, error)
+}
+
+// HTTPPinger measures latency by timing small HTTP requests.
+type HTTPPinger struct {
+	Client http.Client
+	URL    string
+}
+
+// NewPinger creates a new HTTP pinger with the given HTTP client and test
+// server. If the client has no timeout, probes time out after PingTimeout.
+func NewPinger(client http.Client, server Server) HTTPPinger {
+	if client.Timeout == 0 {
+		client.Timeout = PingTimeout
+	}
+	return HTTPPinger{client, serverPath(server, "latency.txt")}
+}
+
+// Ping fetches the latency file, returning the time taken to receive it.
+func (p HTTPPinger) Ping() (time.Duration, error) {
+	url := p.URL + "?x=" + strconv.Itoa(rand.Int())
+	start := time.Now()
+	resp, err := p.Client.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(ioutil.Discard, resp.Body); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// MeasureLatency pings the server the given number of times in succession,
+// returning the measured latencies.
+func MeasureLatency(p Pinger, count int) (Latencies, error) {
+	latencies := make(Latencies, 0, count)
+	for i := 0; i < count; i++ {
+		latency, err := p.Ping()
+		if err != nil {
+			return latencies, err
+		}
+		latencies = append(latencies, latency)
+	}
+	return latencies, nil
+}
+
+// Latencies represents a list of latency measurements.
+type Latencies []time.Duration
+
+// Percentile returns the latency below which the given percentage (0-100) of
+// measurements fall, or zero if there are no measurements.
+func (l Latencies) Percentile(p float64) time.Duration {
+	if len(l) == 0 {
+		return 0
+	}
+	sorted := make(Latencies, len(l))
+	copy(sorted, l)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	pos := int(p/100*float64(len(sorted))+0.5) - 1
+	if pos < 0 {
+		pos = 0
+	} else if pos >= len(sorted) {
+		pos = len(sorted) - 1
+	}
+	return sorted[pos]
+}
+
+// Median returns the median latency.
+func (l Latencies) Median() time.Duration {
+	return l.Percentile(50)
+}
+
+// BufferbloatGrade grades a line from A+ to F by how much its median latency
+// increases under load, or returns an empty string if either set of
+// measurements is empty.
+func BufferbloatGrade(idle, loaded Latencies) string {
+	if len(idle) == 0 || len(loaded) == 0 {
+		return ""
+	}
+	increase := loaded.Median() - idle.Median()
+	switch {
+	case increase < 5*time.Millisecond:
+		return "A+"
+	case increase < 30*time.Millisecond:
+		return "A"
+	case increase < 60*time.Millisecond:
+		return "B"
+	case increase < 200*time.Millisecond:
+		return "C"
+	case increase < 400*time.Millisecond:
+		return "D"
+	default:
+		return "F"
+	}
+}
+
+// probeLatency pings continuously until done is closed, returning the
+// latencies of all successful probes. Failed probes are ignored. The most
+// recent latency is also stored atomically in latest.
+func probeLatency(p Pinger, interval time.Duration, done <-chan struct{}, latest *int64) Latencies {
+	var latencies Latencies
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if latency, err := p.Ping(); err == nil {
+			latencies = append(latencies, latency)
+			atomic.StoreInt64(latest, int64(latency))
+		}
+		select {
+		case <-done:
+			return latencies
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package speedtest
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_LatenciesPercentile(t *testing.T) {
+	Convey("Percentiles should use nearest rank", t, func() {
+		l := Latencies{5, 1, 4, 2, 3, 6, 8, 7, 10, 9}
+		So(l.Percentile(50), ShouldEqual, 5)
+		So(l.Percentile(90), ShouldEqual, 9)
+		So(l.Percentile(100), ShouldEqual, 10)
+		So(l.Percentile(0), ShouldEqual, 1)
+	})
+
+	Convey("Empty latencies should have zero percentiles", t, func() {
+		So(Latencies{}.Median(), ShouldEqual, 0)
+	})
+}
+
+func Test_BufferbloatGrade(t *testing.T) {
+	ms := time.Millisecond
+	idle := Latencies{10 * ms, 12 * ms, 11 * ms}
+
+	Convey("Grade should reflect increase in median latency", t, func() {
+		So(BufferbloatGrade(idle, Latencies{12 * ms}), ShouldEqual, "A+")
+		So(BufferbloatGrade(idle, Latencies{40 * ms}), ShouldEqual, "A")
+		So(BufferbloatGrade(idle, Latencies{100 * ms}), ShouldEqual, "C")
+		So(BufferbloatGrade(idle, Latencies{time.Second}), ShouldEqual, "F")
+	})
+
+	Convey("Grade should be empty without measurements", t, func() {
+		So(BufferbloatGrade(idle, nil), ShouldEqual, "")
+	})
+}
+
+func Test_PingerTimeout(t *testing.T) {
+	Convey("Pingers should give up on stalled servers", t, func() {
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) { <-release }))
+		defer server.Close()
+		defer close(release)
+
+		pinger := NewPinger(http.Client{}, Server{URL: server.URL + "/upload.php"})
+		So(pinger.Client.Timeout, ShouldEqual, PingTimeout)
+		pinger.Client.Timeout = 100 * time.Millisecond
+		start := time.Now()
+		_, err := pinger.Ping()
+		So(err, ShouldNotBeNil)
+		So(time.Since(start), ShouldBeLessThan, time.Second)
+
+		client := http.Client{Timeout: time.Minute}
+		So(NewPinger(client, Server{URL: server.URL + "/upload.php"}).Client.Timeout, ShouldEqual, time.Minute)
+	})
+}
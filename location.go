@@ -0,0 +1,167 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2014 David Johnston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package speedtest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Location is a named point on the Earth's surface.
+type Location struct {
+	Name        string  `json:"name,omitempty" yaml:"name,omitempty"`
+	CountryCode string  `json:"cc,omitempty" yaml:"cc,omitempty"`
+	Lat         float64 `json:"lat" yaml:"lat"`
+	Lon         float64 `json:"lon" yaml:"lon"`
+}
+
+// String returns the location name, or its coordinates if it has no name.
+func (l Location) String() string {
+	if l.Name == "" {
+		return fmt.Sprintf("%v, %v", l.Lat, l.Lon)
+	}
+	if l.CountryCode == "" {
+		return l.Name
+	}
+	return l.Name + ", " + l.CountryCode
+}
+
+// ParseLocation parses a location given either as "lat,lon" in decimal
+// degrees, or as a city name optionally followed by a country code, such as
+// "London" or "London, GB", resolved from the bundled gazetteer.
+func ParseLocation(s string) (Location, error) {
+	s = strings.TrimSpace(s)
+	if fields := strings.Split(s, ","); len(fields) == 2 {
+		lat, latErr := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
+		lon, lonErr := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+		if latErr == nil && lonErr == nil {
+			if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+				return Location{}, fmt.Errorf("coordinates out of range: %q", s)
+			}
+			return Location{Lat: lat, Lon: lon}, nil
+		}
+	}
+	if l, ok := LookupCity(s); ok {
+		return l, nil
+	}
+	return Location{}, fmt.Errorf("unknown location %q", s)
+}
+
+// LookupCity finds a city in the bundled gazetteer by name, ignoring case.
+// The name may be followed by a comma and country code to distinguish
+// cities with the same name.
+func LookupCity(name string) (Location, bool) {
+	cc := ""
+	if i := strings.LastIndex(name, ","); i >= 0 {
+		name, cc = name[:i], strings.TrimSpace(name[i+1:])
+	}
+	name = strings.TrimSpace(name)
+	for _, city := range cities {
+		if strings.EqualFold(city.Name, name) &&
+			(cc == "" || strings.EqualFold(city.CountryCode, cc)) {
+			return city, true
+		}
+	}
+	return Location{}, false
+}
+
+// cities is a small gazetteer of major cities, ordered so that the most
+// populous of any cities sharing a name comes first.
+var cities = []Location{
+	{"Amsterdam", "NL", 52.3676, 4.9041},
+	{"Athens", "GR", 37.9838, 23.7275},
+	{"Atlanta", "US", 33.7490, -84.3880},
+	{"Auckland", "NZ", -36.8485, 174.7633},
+	{"Bangkok", "TH", 13.7563, 100.5018},
+	{"Barcelona", "ES", 41.3874, 2.1686},
+	{"Beijing", "CN", 39.9042, 116.4074},
+	{"Berlin", "DE", 52.5200, 13.4050},
+	{"Birmingham", "GB", 52.4862, -1.8904},
+	{"Bogota", "CO", 4.7110, -74.0721},
+	{"Boston", "US", 42.3601, -71.0589},
+	{"Brussels", "BE", 50.8503, 4.3517},
+	{"Bucharest", "RO", 44.4268, 26.1025},
+	{"Budapest", "HU", 47.4979, 19.0402},
+	{"Buenos Aires", "AR", -34.6037, -58.3816},
+	{"Cairo", "EG", 30.0444, 31.2357},
+	{"Cape Town", "ZA", -33.9249, 18.4241},
+	{"Chicago", "US", 41.8781, -87.6298},
+	{"Copenhagen", "DK", 55.6761, 12.5683},
+	{"Dallas", "US", 32.7767, -96.7970},
+	{"Delhi", "IN", 28.7041, 77.1025},
+	{"Denver", "US", 39.7392, -104.9903},
+	{"Dubai", "AE", 25.2048, 55.2708},
+	{"Dublin", "IE", 53.3498, -6.2603},
+	{"Edinburgh", "GB", 55.9533, -3.1883},
+	{"Frankfurt", "DE", 50.1109, 8.6821},
+	{"Glasgow", "GB", 55.8642, -4.2518},
+	{"Hamburg", "DE", 53.5511, 9.9937},
+	{"Helsinki", "FI", 60.1699, 24.9384},
+	{"Hong Kong", "HK", 22.3193, 114.1694},
+	{"Istanbul", "TR", 41.0082, 28.9784},
+	{"Jakarta", "ID", -6.2088, 106.8456},
+	{"Johannesburg", "ZA", -26.2041, 28.0473},
+	{"Kyiv", "UA", 50.4501, 30.5234},
+	{"Lagos", "NG", 6.5244, 3.3792},
+	{"Lisbon", "PT", 38.7223, -9.1393},
+	{"London", "GB", 51.5074, -0.1278},
+	{"London", "CA", 42.9849, -81.2453},
+	{"Los Angeles", "US", 34.0522, -118.2437},
+	{"Madrid", "ES", 40.4168, -3.7038},
+	{"Manchester", "GB", 53.4808, -2.2426},
+	{"Melbourne", "AU", -37.8136, 144.9631},
+	{"Mexico City", "MX", 19.4326, -99.1332},
+	{"Miami", "US", 25.7617, -80.1918},
+	{"Milan", "IT", 45.4642, 9.1900},
+	{"Montreal", "CA", 45.5017, -73.5673},
+	{"Moscow", "RU", 55.7558, 37.6173},
+	{"Mumbai", "IN", 19.0760, 72.8777},
+	{"Munich", "DE", 48.1351, 11.5820},
+	{"Nairobi", "KE", -1.2921, 36.8219},
+	{"New York", "US", 40.7128, -74.0060},
+	{"Oslo", "NO", 59.9139, 10.7522},
+	{"Paris", "FR", 48.8566, 2.3522},
+	{"Prague", "CZ", 50.0755, 14.4378},
+	{"Rio de Janeiro", "BR", -22.9068, -43.1729},
+	{"Rome", "IT", 41.9028, 12.4964},
+	{"San Francisco", "US", 37.7749, -122.4194},
+	{"Santiago", "CL", -33.4489, -70.6693},
+	{"Sao Paulo", "BR", -23.5505, -46.6333},
+	{"Seattle", "US", 47.6062, -122.3321},
+	{"Seoul", "KR", 37.5665, 126.9780},
+	{"Shanghai", "CN", 31.2304, 121.4737},
+	{"Singapore", "SG", 1.3521, 103.8198},
+	{"Stockholm", "SE", 59.3293, 18.0686},
+	{"Sydney", "AU", -33.8688, 151.2093},
+	{"Taipei", "TW", 25.0330, 121.5654},
+	{"Tokyo", "JP", 35.6762, 139.6503},
+	{"Toronto", "CA", 43.6532, -79.3832},
+	{"Vancouver", "CA", 49.2827, -123.1207},
+	{"Vienna", "AT", 48.2082, 16.3738},
+	{"Warsaw", "PL", 52.2297, 21.0122},
+	{"Washington", "US", 38.9072, -77.0369},
+	{"Zurich", "CH", 47.3769, 8.5417},
+}
@@ -0,0 +1,47 @@
+package speedtest
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func Test_ParseLocation(t *testing.T) {
+	Convey("Coordinates should be parsed as decimal degrees", t, func() {
+		l, err := ParseLocation("51.5, -0.13")
+		So(err, ShouldBeNil)
+		So(l.Lat, ShouldEqual, 51.5)
+		So(l.Lon, ShouldEqual, -0.13)
+		So(l.String(), ShouldEqual, "51.5, -0.13")
+	})
+
+	Convey("Coordinates out of range should be rejected", t, func() {
+		_, err := ParseLocation("91,0")
+		So(err, ShouldNotBeNil)
+		_, err = ParseLocation("0,181")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("City names should be resolved ignoring case", t, func() {
+		l, err := ParseLocation("new york")
+		So(err, ShouldBeNil)
+		So(l.CountryCode, ShouldEqual, "US")
+		So(l.Lat, ShouldAlmostEqual, 40.71, 0.01)
+	})
+
+	Convey("Country codes should distinguish cities", t, func() {
+		l, err := ParseLocation("London")
+		So(err, ShouldBeNil)
+		So(l.CountryCode, ShouldEqual, "GB")
+		l, err = ParseLocation("London, CA")
+		So(err, ShouldBeNil)
+		So(l.CountryCode, ShouldEqual, "CA")
+		So(l.String(), ShouldEqual, "London, CA")
+	})
+
+	Convey("Unknown places should be rejected", t, func() {
+		_, err := ParseLocation("Atlantis")
+		So(err, ShouldNotBeNil)
+		_, err = ParseLocation("London, XX")
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,159 @@
+package speedtest
+
+import (
+	"encoding/binary"
+	. "github.com/smartystreets/goconvey/convey"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+// connectProxy is a minimal HTTP proxy supporting only the CONNECT method.
+func connectProxy(tunnels *int32) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "CONNECT" {
+			http.Error(w, "CONNECT only", http.StatusMethodNotAllowed)
+			return
+		}
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		atomic.AddInt32(tunnels, 1)
+		go func() {
+			io.Copy(upstream, conn)
+			upstream.Close()
+		}()
+		io.Copy(conn, upstream)
+		conn.Close()
+	})
+}
+
+// socksProxy is a minimal SOCKS5 proxy supporting only unauthenticated
+// CONNECT requests. It serves connections until the listener is closed.
+func socksProxy(l net.Listener, tunnels *int32) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			upstream, err := socksHandshake(conn)
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(tunnels, 1)
+			go func() {
+				io.Copy(upstream, conn)
+				upstream.Close()
+			}()
+			io.Copy(conn, upstream)
+		}()
+	}
+}
+
+// socksHandshake negotiates a SOCKS5 CONNECT request, returning the
+// connection to the requested address.
+func socksHandshake(conn net.Conn) (net.Conn, error) {
+	// Greeting: version, methods; choose "no authentication"
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(conn, make([]byte, header[1])); err != nil {
+		return nil, err
+	}
+	conn.Write([]byte{5, 0})
+
+	// Request: version, command, reserved, address type, address, port
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return nil, err
+	}
+	var host string
+	switch request[3] {
+	case 1, 4:
+		ip := make(net.IP, map[byte]int{1: 4, 4: 16}[request[3]])
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return nil, err
+		}
+		host = ip.String()
+	case 3:
+		n := make([]byte, 1)
+		if _, err := io.ReadFull(conn, n); err != nil {
+			return nil, err
+		}
+		name := make([]byte, n[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return nil, err
+		}
+		host = string(name)
+	}
+	var port uint16
+	if err := binary.Read(conn, binary.BigEndian, &port); err != nil {
+		return nil, err
+	}
+
+	upstream, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return nil, err
+	}
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	return upstream, nil
+}
+
+func Test_Proxy(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("hello"))
+		}))
+	defer ts.Close()
+
+	var tunnels int32
+	proxy := httptest.NewServer(connectProxy(&tunnels))
+	defer proxy.Close()
+
+	Convey("Connections should be tunnelled through HTTP proxies", t, func() {
+		proxyURL, _ := url.Parse(proxy.URL)
+		client, err := NewClient(ClientOptions{Proxy: proxyURL})
+		So(err, ShouldBeNil)
+
+		body, err := NewAPI(client).Fetch(ts.URL)
+		So(err, ShouldBeNil)
+		So(string(body), ShouldEqual, "hello")
+		So(atomic.LoadInt32(&tunnels), ShouldEqual, 1)
+	})
+
+	Convey("Connections should be made through SOCKS5 proxies", t, func() {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		defer l.Close()
+		var socksTunnels int32
+		go socksProxy(l, &socksTunnels)
+
+		proxyURL, _ := url.Parse("socks5://" + l.Addr().String())
+		client, err := NewClient(ClientOptions{Proxy: proxyURL})
+		So(err, ShouldBeNil)
+
+		body, err := NewAPI(client).Fetch(ts.URL)
+		So(err, ShouldBeNil)
+		So(string(body), ShouldEqual, "hello")
+		So(atomic.LoadInt32(&socksTunnels), ShouldEqual, 1)
+	})
+
+	Convey("Unsupported proxies should be rejected", t, func() {
+		proxyURL, _ := url.Parse("ftp://localhost:21")
+		_, err := NewClient(ClientOptions{Proxy: proxyURL})
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,173 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2014 David Johnston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package speedtest
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Rate is a data transfer rate in bytes/sec.
+type Rate float64
+
+// RateFormat describes how a Rate is formatted.
+type RateFormat struct {
+	// Binary selects IEC units (powers of 1024, such as Mibit/s) rather than
+	// SI units (powers of 1000, such as Mbps).
+	Binary bool
+	// Bytes selects units of bytes/sec rather than bits/sec.
+	Bytes bool
+	// Unit fixes the unit, such as "Mbps" or "MiB/s", overriding Binary and
+	// Bytes. If empty or not understood by ParseRate, a unit suiting the
+	// rate is chosen.
+	Unit string
+	// Precision is the number of decimal places.
+	Precision int
+}
+
+// DefaultRateFormat formats rates in SI bits/sec to two decimal places, as
+// advertised by most ISPs.
+var DefaultRateFormat = RateFormat{Precision: 2}
+
+// String formats the rate using DefaultRateFormat, such as "94.21Mbps".
+func (r Rate) String() string {
+	return r.Format(DefaultRateFormat)
+}
+
+// Format formats the rate as described by f.
+func (r Rate) Format(f RateFormat) string {
+	size, unit := f.unit(r)
+	return strconv.FormatFloat(float64(r)/size, 'f', f.Precision, 64) + unit
+}
+
+// FormatMargin formats the rate along with its margin of error, in the same
+// unit, such as "94.21Mbps ±3.10".
+func (r Rate) FormatMargin(margin Rate, f RateFormat) string {
+	size, _ := f.unit(r)
+	return fmt.Sprintf("%v ±%.*f", r.Format(f), f.Precision,
+		float64(margin)/size)
+}
+
+// unit chooses the unit in which to format the rate, and its size in
+// bytes/sec.
+func (f RateFormat) unit(r Rate) (float64, string) {
+	if f.Unit != "" {
+		if size, _, err := parseRateUnit(f.Unit); err == nil {
+			return size, f.Unit
+		}
+	}
+
+	base := 1000.0
+	if f.Binary {
+		base = 1024
+	}
+	value := float64(r)
+	if !f.Bytes {
+		value *= 8
+	}
+	exp := 0
+	for exp < len(ratePrefixes) && math.Abs(value) >= base {
+		value /= base
+		exp++
+	}
+
+	size := math.Pow(base, float64(exp))
+	prefix := ""
+	if exp > 0 {
+		prefix = ratePrefixes[exp-1 : exp]
+		if f.Binary {
+			prefix = strings.ToUpper(prefix) + "i"
+		}
+	}
+	switch {
+	case f.Bytes:
+		return size, prefix + "B/s"
+	case f.Binary:
+		return size / 8, prefix + "bit/s"
+	default:
+		return size / 8, prefix + "bps"
+	}
+}
+
+// ratePrefixes lists the SI prefixes of each power of the unit base.
+const ratePrefixes = "kMGT"
+
+// ParseRate parses a rate such as "100Mbps", "12.5MB/s" or "1Gibit/s". Units
+// with a lowercase "b" are bits and an uppercase "B" are bytes; SI prefixes
+// are powers of 1000 and IEC prefixes are powers of 1024.
+func ParseRate(s string) (Rate, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(s)
+	}
+	value, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate %q", s)
+	}
+	size, _, err := parseRateUnit(strings.TrimSpace(s[i:]))
+	if err != nil {
+		return 0, fmt.Errorf("%v in rate %q", err, s)
+	}
+	return Rate(value * size), nil
+}
+
+// ParseRateUnit parses a unit such as "Mbps" or "MiB/s", returning a format
+// that fixes rates to that unit.
+func ParseRateUnit(unit string) (RateFormat, error) {
+	_, f, err := parseRateUnit(unit)
+	return f, err
+}
+
+// parseRateUnit returns the size in bytes/sec of a unit such as "Mbps",
+// "MB/s" or "Gibit/s", and the format it implies.
+func parseRateUnit(unit string) (float64, RateFormat, error) {
+	f := RateFormat{Unit: unit, Precision: DefaultRateFormat.Precision}
+	size, u := 1.0, unit
+	if len(u) > 1 {
+		if exp := strings.IndexByte("kmgt", u[0]|0x20); exp >= 0 {
+			base := 1000.0
+			if u[1] == 'i' {
+				base, f.Binary, u = 1024, true, u[1:]
+			}
+			size, u = math.Pow(base, float64(exp+1)), u[1:]
+		}
+	}
+	switch u {
+	case "":
+		return 0, f, fmt.Errorf("missing unit")
+	case "bps", "b/s", "bit/s", "bits/s":
+		size /= 8
+	case "Bps", "B/s", "byte/s", "bytes/s":
+		f.Bytes = true
+	default:
+		return 0, f, fmt.Errorf("unknown unit %q", unit)
+	}
+	return size, f, nil
+}
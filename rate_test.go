@@ -0,0 +1,72 @@
+package speedtest
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func Test_Rate(t *testing.T) {
+	Convey("Rates should be formatted in SI bits/sec by default", t, func() {
+		So(Rate(12500000).String(), ShouldEqual, "100.00Mbps")
+		So(Rate(125).String(), ShouldEqual, "1.00kbps")
+		So(Rate(100).String(), ShouldEqual, "800.00bps")
+		So(Rate(125e9).String(), ShouldEqual, "1.00Tbps")
+		So(Rate(0).String(), ShouldEqual, "0.00bps")
+	})
+
+	Convey("Rates should be formatted in the chosen units", t, func() {
+		r := Rate(12500000)
+		So(r.Format(RateFormat{Bytes: true, Precision: 1}), ShouldEqual, "12.5MB/s")
+		So(r.Format(RateFormat{Binary: true, Precision: 2}), ShouldEqual, "95.37Mibit/s")
+		So(r.Format(RateFormat{Binary: true, Bytes: true, Precision: 2}), ShouldEqual, "11.92MiB/s")
+		So(r.Format(RateFormat{Unit: "kbps"}), ShouldEqual, "100000kbps")
+		So(r.Format(RateFormat{Unit: "Gbit/s", Precision: 3}), ShouldEqual, "0.100Gbit/s")
+		So(r.Format(RateFormat{Unit: "furlongs", Precision: 0}), ShouldEqual, "100Mbps")
+		So(r.FormatMargin(125000, RateFormat{Bytes: true, Precision: 2}), ShouldEqual, "12.50MB/s ±0.12")
+	})
+
+	Convey("Rates should be parsed from bits or bytes per second", t, func() {
+		for s, want := range map[string]Rate{
+			"100Mbps":  12500000,
+			"12.5MB/s": 12500000,
+			"1Gibit/s": 1 << 27,
+			"1 KiB/s":  1024,
+			"800 bps":  100,
+			"8kb/s":    1000,
+			"10mbps":   1250000,
+			"2bytes/s": 2,
+			"1.5 TBps": 1.5e12,
+			"64 Kibps": 8192,
+		} {
+			r, err := ParseRate(s)
+			So(err, ShouldBeNil)
+			So(r, ShouldAlmostEqual, want, 1e-6)
+		}
+
+		for _, s := range []string{"", "100", "fast", "10 Mb/min", "1.2.3Mbps", "5 kib"} {
+			_, err := ParseRate(s)
+			So(err, ShouldNotBeNil)
+		}
+	})
+
+	Convey("Formatted rates should parse to the same rate", t, func() {
+		for _, f := range []RateFormat{
+			{Precision: 6}, {Binary: true, Precision: 6},
+			{Bytes: true, Precision: 6}, {Binary: true, Bytes: true, Precision: 6},
+		} {
+			for _, r := range []Rate{1, 999, 12345, 12500000, 3e9} {
+				parsed, err := ParseRate(r.Format(f))
+				So(err, ShouldBeNil)
+				So(float64(parsed), ShouldAlmostEqual, float64(r), float64(r)*1e-5)
+			}
+		}
+	})
+
+	Convey("Units should imply a fixed format", t, func() {
+		f, err := ParseRateUnit("MiB/s")
+		So(err, ShouldBeNil)
+		So(f, ShouldResemble, RateFormat{Binary: true, Bytes: true, Unit: "MiB/s", Precision: 2})
+		_, err = ParseRateUnit("MiB")
+		So(err, ShouldNotBeNil)
+	})
+}
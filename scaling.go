@@ -0,0 +1,97 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2014 David Johnston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package speedtest
+
+import "time"
+
+const (
+	// scaleInterval is how often adaptive scaling measures throughput.
+	scaleInterval = 500 * time.Millisecond
+	// scaleMinGain is the fractional throughput gain an added thread must
+	// produce for scaling to continue.
+	scaleMinGain = 0.05
+	// scaleMaxLoss is the fractional throughput loss that causes a thread
+	// to be removed.
+	scaleMaxLoss = 0.15
+	// scaleDrops is the number of consecutive losses that cause a thread to
+	// be removed, so that noise alone doesn't.
+	scaleDrops = 2
+)
+
+// Scaling is a strategy for choosing how many concurrent requests a benchmark
+// makes.
+type Scaling int
+
+const (
+	// ScaleOnCompletion adds a thread each time a request completes, until
+	// the maximum number of threads is reached.
+	ScaleOnCompletion Scaling = iota
+	// ScaleAdaptive adds threads only while each one improves throughput,
+	// and removes them again if throughput drops.
+	ScaleAdaptive
+)
+
+// scaler decides how to adjust concurrency given the throughput achieved at
+// the current level.
+type scaler struct {
+	MaxThreads int
+	rate       float64
+	plateau    bool
+	drops      int
+}
+
+// Adjust returns the number of threads to add (or, if negative, remove)
+// given the current number of threads and the throughput measured since the
+// last adjustment.
+func (s *scaler) Adjust(threads int, rate float64) int {
+	if rate < s.rate*(1-scaleMaxLoss) && threads > 1 {
+		if s.drops++; s.drops < scaleDrops {
+			return 0
+		}
+		// Throughput dropped repeatedly; back off, and scale again from the
+		// new level if it recovers
+		s.drops = 0
+		s.rate = rate
+		s.plateau = false
+		return -1
+	}
+	s.drops = 0
+
+	switch {
+	case s.plateau:
+		return 0
+	case rate > s.rate*(1+scaleMinGain) && threads < s.MaxThreads:
+		// Last thread helped; try another
+		s.rate = rate
+		return 1
+	default:
+		// Gains have flattened; stop scaling
+		s.plateau = true
+		if rate > s.rate {
+			s.rate = rate
+		}
+		return 0
+	}
+}
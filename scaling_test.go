@@ -0,0 +1,57 @@
+package speedtest
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func Test_Scaler(t *testing.T) {
+	Convey("Scaler should add threads while throughput improves", t, func() {
+		s := scaler{MaxThreads: 8}
+		So(s.Adjust(1, 100), ShouldEqual, 1)
+		So(s.Adjust(2, 200), ShouldEqual, 1)
+		So(s.Adjust(3, 280), ShouldEqual, 1)
+
+		Convey("and stop once gains flatten", func() {
+			So(s.Adjust(4, 285), ShouldEqual, 0)
+			So(s.Adjust(4, 400), ShouldEqual, 0)
+		})
+
+		Convey("and back off if throughput drops repeatedly", func() {
+			So(s.Adjust(4, 200), ShouldEqual, 0)
+			So(s.Adjust(4, 200), ShouldEqual, -1)
+
+			Convey("and scale up again if it recovers", func() {
+				So(s.Adjust(3, 280), ShouldEqual, 1)
+			})
+		})
+
+		Convey("and not back off after a single drop", func() {
+			So(s.Adjust(4, 200), ShouldEqual, 0)
+			So(s.Adjust(4, 280), ShouldEqual, 0)
+			So(s.Adjust(4, 200), ShouldEqual, 0)
+		})
+	})
+
+	Convey("Scaler should hold its level given a noisy but flat rate", t, func() {
+		// Throughput saturates at four threads, varying by up to 20%
+		r := rand.New(rand.NewSource(1))
+		s := scaler{MaxThreads: 16}
+		threads, total := 1, 0
+		for i := 0; i < 200; i++ {
+			rate := 100 * math.Min(float64(threads), 4) * (0.8 + 0.4*r.Float64())
+			threads += s.Adjust(threads, rate)
+			So(threads, ShouldBeGreaterThanOrEqualTo, 2)
+			total += threads
+		}
+		So(float64(total)/200, ShouldBeGreaterThanOrEqualTo, 3)
+	})
+
+	Convey("Scaler should not exceed maximum threads", t, func() {
+		s := scaler{MaxThreads: 2}
+		So(s.Adjust(1, 100), ShouldEqual, 1)
+		So(s.Adjust(2, 200), ShouldEqual, 0)
+	})
+}
@@ -0,0 +1,72 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2014 David Johnston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package speedtest
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// LoadSettings loads server definitions from a local file. Files with an
+// .xml extension are read in the same format as the speedtest.net server
+// list. Files with a .json extension contain a list of servers, for example:
+//
+//	{"servers": [{
+//	  "id": 1,
+//	  "url": "http://speedtest.example.com/speedtest/upload.php",
+//	  "lat": 51.5,
+//	  "lon": -0.13,
+//	  "name": "London",
+//	  "country": "United Kingdom",
+//	  "cc": "GB",
+//	  "sponsor": "Example"
+//	}]}
+//
+// Settings also carry yaml tags, so that the same list may be decoded from
+// YAML by a YAML package.
+func LoadSettings(path string) (Settings, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Settings{}, err
+	}
+
+	settings := Settings{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".xml":
+		err = xml.Unmarshal(data, &settings)
+	case ".json":
+		err = json.Unmarshal(data, &settings)
+	default:
+		return Settings{}, fmt.Errorf("unknown server file format %q", ext)
+	}
+	if err != nil {
+		return Settings{}, fmt.Errorf("%v: %v", path, err)
+	}
+	return settings, nil
+}
@@ -0,0 +1,51 @@
+package speedtest
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_LoadSettings(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "speedtest")
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		ioutil.WriteFile(path, []byte(content), 0644)
+		return path
+	}
+
+	Convey("Settings should load from XML", t, func() {
+		settings, err := LoadSettings(write("servers.xml", testSettings))
+		So(err, ShouldBeNil)
+		So(len(settings.Servers), ShouldEqual, 1)
+		So(settings.Servers[0].CountryCode, ShouldEqual, "GB")
+	})
+
+	Convey("Settings should load from JSON", t, func() {
+		settings, err := LoadSettings(write("servers.json", `{"servers": [
+			{"id": 7, "url": "http://b.example/speedtest/upload.php",
+			 "lat": 48.9, "lon": 2.4, "cc": "FR", "sponsor": "Exemple"}]}`))
+		So(err, ShouldBeNil)
+		So(len(settings.Servers), ShouldEqual, 1)
+		So(settings.Servers[0].ID, ShouldEqual, 7)
+		So(settings.Servers[0].Lat, ShouldEqual, 48.9)
+	})
+
+	Convey("Unknown formats should be rejected", t, func() {
+		_, err := LoadSettings(write("servers.txt", ""))
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func Test_ServersMerge(t *testing.T) {
+	Convey("Merged servers should replace those with the same ID", t, func() {
+		servers := Servers{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}
+		merged := servers.Merge(Servers{{ID: 2, Name: "c"}, {ID: 3, Name: "d"}})
+		So(len(merged), ShouldEqual, 3)
+		So(merged[1].Name, ShouldEqual, "c")
+	})
+}
@@ -25,140 +25,628 @@ THE SOFTWARE.
 package main
 
 import (
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"github.com/johnsto/speedtest"
+	"github.com/johnsto/speedtest/geoip"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 )
 
 const (
-	findNearest = -1
+	findNearest  = -1
 	findFarthest = -2
 )
 
 var (
-	cmdListServers   string
-	testUpload       bool
-	testDownload     bool
-	httpTimeout      time.Duration
-	sampleServer     int
-	samplePeriod     time.Duration
-	sampleThreads    int
-	sampleMaxThreads int
+	httpTimeout     time.Duration
+	verbose         bool
+	forceIPv4       bool
+	forceIPv6       bool
+	sourceAddr      string
+	sourceInterface string
+	proxyURL        string
+	useHTTPS        bool
+	httpVersion     string
+	insecure        bool
+	cacheTTL        time.Duration
+	noCache         bool
+	serversFile     string
+	serversFileOnly bool
+	filterCountry   string
+	filterSponsor   string
+	filterName      string
+	filterDistance  float64
+	filterExclude   string
+	location        string
+	geoIPDatabase   string
+	vincenty        bool
+	sampleServer    int
 )
 
+// out receives progress and results. Commands writing JSON to stdout send
+// everything else to stderr.
+var out io.Writer = os.Stdout
+
+// command is a subcommand of the CLI.
+type command struct {
+	name    string
+	summary string
+	// flags registers the command's flags.
+	flags func(fs *flag.FlagSet)
+	// run runs the command once its flags have been parsed.
+	run func(fs *flag.FlagSet)
+}
+
+// commands lists the subcommands, the first of which is the default.
+var commands []command
+
 func init() {
-	flag.StringVar(&cmdListServers, "list-servers", "",
-		"List servers (id|distance|nearest|farthest)")
+	commands = []command{
+		{"run", "Test bandwidth and latency (default)", runFlags, runCommand},
+		{"servers", "List servers", serversFlags, serversCommand},
+		{"ping", "Measure latency to a server", pingFlags, pingCommand},
+		{"history", "Show results of previous runs", historyFlags, historyCommand},
+		{"serve", "Run a test server", serveFlags, serveCommand},
+		{"report", "Render a saved result as HTML and SVG", reportFlags, reportCommand},
+		{"config", "Show the configuration ('config show [command]')",
+			configCommandFlags, configCommand},
+	}
+}
+
+func main() {
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "help" {
+		help(args[1:])
+		return
+	}
+	cmd, args, ok := parseCommand(args)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", args[0])
+		usage()
+		os.Exit(2)
+	}
+
+	known := knownFlags()
+	fs := newFlagSet(cmd)
+	fs.Parse(args)
+	if cmd.name != "config" {
+		if _, err := configure(fs, cmd.name, known); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	cmd.run(fs)
+}
+
+// parseCommand finds the command named by the first argument, returning it
+// with the remaining arguments. Without a command name, run is chosen.
+func parseCommand(args []string) (command, []string, bool) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return commands[0], args, true
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c, args[1:], true
+		}
+	}
+	return command{}, args, false
+}
 
-	flag.BoolVar(&testUpload, "test-upload", true, "Test upload speed")
-	flag.BoolVar(&testDownload, "test-download", true, "Test download speed")
+// newFlagSet creates the flag set for a command.
+func newFlagSet(cmd command) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ExitOnError)
+	cmd.flags(fs)
+	configFlag(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: speedtest-cli %v [flags]\n\n%v.\n\n",
+			cmd.name, cmd.summary)
+		fmt.Fprintf(fs.Output(), "Flags:\n")
+		fs.PrintDefaults()
+		if cmd.name == commands[0].name {
+			fmt.Fprintln(fs.Output())
+			usage()
+		}
+	}
+	return fs
+}
 
-	flag.IntVar(&sampleServer, "server", findNearest,
-		"Server id to test (-1: use nearest, -2: use farthest)")
+// usage lists the available commands.
+func usage() {
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10v %v\n", c.name, c.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun 'speedtest-cli help <command>' for its flags.\n")
+	fmt.Fprintf(os.Stderr, "Flags may also be set by environment variables "+
+		"such as %v, or in a configuration file.\n", envName("max-threads"))
+}
+
+// help prints the usage of the named command, or lists the commands.
+func help(args []string) {
+	if len(args) > 0 {
+		for _, c := range commands {
+			if c.name == args[0] {
+				newFlagSet(c).Usage()
+				return
+			}
+		}
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", args[0])
+	}
+	usage()
+}
 
-	flag.DurationVar(&httpTimeout, "timeout", time.Duration(10*time.Second),
+// connectionFlags registers flags controlling how connections are made.
+func connectionFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&forceIPv4, "4", false, "Connect over IPv4 only")
+	fs.BoolVar(&forceIPv6, "6", false, "Connect over IPv6 only")
+	fs.StringVar(&sourceAddr, "source", "",
+		"Local IP address to connect from")
+	fs.StringVar(&sourceInterface, "interface", "",
+		"Network interface to connect from")
+	fs.BoolVar(&useHTTPS, "https", false,
+		"Connect to the API and servers using HTTPS")
+	fs.StringVar(&httpVersion, "http-version", "",
+		"Force HTTP version (1.1|2), otherwise negotiated")
+	fs.BoolVar(&insecure, "insecure", false,
+		"Don't verify HTTPS certificates")
+	fs.StringVar(&proxyURL, "proxy", "",
+		"Proxy to connect through (http://host:port or socks5://host:port)")
+	fs.DurationVar(&httpTimeout, "timeout", time.Duration(10*time.Second),
 		"HTTP connection timeout")
-	flag.DurationVar(&samplePeriod, "period", time.Duration(10*time.Second),
-		"Sampling period")
-	flag.IntVar(&sampleThreads, "threads", 4,
-		"Initial number of benchmark threads")
-	flag.IntVar(&sampleMaxThreads, "max-threads", 16,
-		"Maximum number of benchmark threads")
+	fs.BoolVar(&verbose, "verbose", false,
+		"Print timings of each phase of HTTP requests")
 }
 
-func main() {
-	flag.Parse()
+// serverListFlags registers flags controlling where servers come from, and
+// how far away they are.
+func serverListFlags(fs *flag.FlagSet) {
+	fs.StringVar(&serversFile, "servers-file", "",
+		"Load additional servers from a file (.xml, .json or .yaml)")
+	fs.BoolVar(&serversFileOnly, "servers-file-only", false,
+		"Use only the servers from -servers-file")
+	fs.DurationVar(&cacheTTL, "cache-ttl", 24*time.Hour,
+		"How long to use the cached server list before revalidating it")
+	fs.BoolVar(&noCache, "no-cache", false,
+		"Don't cache the server list and config")
+
+	fs.StringVar(&location, "location", "",
+		"Client location as 'lat,lon' or a city name, overriding the API")
+	fs.StringVar(&geoIPDatabase, "geoip-db", "",
+		"MaxMind-format database used to locate the client")
+	fs.BoolVar(&vincenty, "vincenty", false,
+		"Calculate distances on the WGS-84 ellipsoid rather than a sphere")
+
+	fs.StringVar(&filterCountry, "country", "",
+		"Only list or select servers with this country code")
+	fs.StringVar(&filterSponsor, "sponsor", "",
+		"Only list or select servers whose sponsor contains this text")
+	fs.StringVar(&filterName, "name", "",
+		"Only list or select servers whose name matches this expression")
+	fs.Float64Var(&filterDistance, "max-distance", 0,
+		"Only list or select servers within this distance (km)")
+	fs.StringVar(&filterExclude, "exclude", "",
+		"Comma-separated server ids never to list or select")
+}
+
+// byteSize is a flag.Value holding a quantity of data, such as "50MB".
+type byteSize struct {
+	text  string
+	bytes int64
+}
 
-	fmt.Printf("Fetching server list... ")
-	settings, err := speedtest.FetchSettings()
+func (b *byteSize) String() string { return b.text }
+
+func (b *byteSize) Set(s string) error {
+	n, err := speedtest.ParseBytes(s)
+	if err != nil {
+		return err
+	}
+	b.text, b.bytes = s, n
+	return nil
+}
+
+// rateUnits is a flag.Value choosing the units in which rates are shown.
+type rateUnits struct {
+	text   string
+	format speedtest.RateFormat
+}
+
+func (u *rateUnits) String() string { return u.text }
+
+func (u *rateUnits) Set(s string) error {
+	f := speedtest.DefaultRateFormat
+	switch s {
+	case "si":
+	case "iec":
+		f.Binary = true
+	case "si-bytes":
+		f.Bytes = true
+	case "iec-bytes":
+		f.Binary, f.Bytes = true, true
+	default:
+		var err error
+		if f, err = speedtest.ParseRateUnit(s); err != nil {
+			return err
+		}
+	}
+	u.text, u.format = s, f
+	return nil
+}
+
+// units holds the units in which rates are shown.
+var units = rateUnits{"si", speedtest.DefaultRateFormat}
+
+// unitsFlag registers the -units flag.
+func unitsFlag(fs *flag.FlagSet) {
+	fs.Var(&units, "units", "Units in which to show rates: si (Mbps), "+
+		"iec (Mibit/s), si-bytes (MB/s), iec-bytes (MiB/s) or a unit such as Gbps")
+}
+
+// niceRate formats a rate measured in bytes/sec in the chosen units.
+func niceRate(rate int) string {
+	return speedtest.Rate(rate).Format(units.format)
+}
+
+// session holds the state shared by commands that talk to servers.
+type session struct {
+	Client   http.Client
+	Config   speedtest.Config
+	Location speedtest.Location
+	Settings speedtest.Settings
+	// Filtered holds the servers matching the filter flags.
+	Filtered speedtest.Servers
+	// Proxy is the proxy connections are made through, if any.
+	Proxy *url.URL
+}
+
+// connect creates a client, fetches the server list and config, and
+// calculates server distances, printing progress. It exits on failure.
+func connect() session {
+	var s session
+	if forceIPv4 && forceIPv6 {
+		fmt.Fprintln(out, "Only one of -4 and -6 may be given")
+		os.Exit(2)
+	}
+	client, err := newClient("")
+	if err != nil {
+		fmt.Fprintf(out, "Couldn't configure connection: %v\n", err)
+		os.Exit(1)
+	}
+	s.Client = client
+	if s.Proxy = usedProxy(); s.Proxy != nil {
+		fmt.Fprintf(out, "Using proxy %v\n", s.Proxy.Redacted())
+	}
+
+	api := speedtest.NewAPI(client)
+	if useHTTPS {
+		api.SettingsURL = secure(api.SettingsURL)
+		api.ConfigURL = secure(api.ConfigURL)
+	}
+	if verbose {
+		api.Trace = speedtest.NewTraceRecorder()
+	}
+
+	s.Settings, err = loadSettings(api)
 	if err != nil {
-		fmt.Printf("error: %v", err)
+		fmt.Fprintf(out, "error: %v\n", err)
 		os.Exit(1)
 	}
-	fmt.Printf("%v found.\n", len(settings.Servers))
 
-	fmt.Printf("Fetching config...\n")
-	config, err := speedtest.FetchConfig()
+	fmt.Fprintf(out, "Fetching config...\n")
+	s.Config, err = fetchConfig(api)
+	if err != nil && serversFileOnly {
+		// Private servers can be tested without the API
+		fmt.Fprintf(out, "warning: couldn't read config: %v\n", err)
+	} else if err != nil {
+		fmt.Fprintf(out, "Couldn't read config: %v\n", err)
+		os.Exit(1)
+	}
+	loc, source, err := clientLocation(s.Config)
 	if err != nil {
-		fmt.Printf("Couldn't read config: %v", err)
+		fmt.Fprintf(out, "Couldn't determine location: %v\n", err)
 		os.Exit(1)
 	}
-	settings.UpdateDistances(config.Client.Lat, config.Client.Lon)
-
-	fmt.Printf("  ISP: %v\n", config.Client.IspName)
-	fmt.Printf("  Location: %v, %v\n\n", config.Client.Lat, config.Client.Lon)
-
-	// List servers
-	if cmdListServers != "" {
-		var listing = settings.Servers
-		switch cmdListServers {
-		case "id":
-			settings.Servers.SortByID()
-		case "distance":
-			settings.Servers.SortByDistance()
-		case "nearest":
-			settings.Servers.SortByDistance()
-			listing = settings.Servers[:10]
-		case "farthest":
-			settings.Servers.SortByDistance()
-			listing = settings.Servers[len(listing)-10:]
-		}
-		for _, server := range listing {
-			fmt.Printf("%5d. [%v] (%dkm) %v\n",
-				server.ID, server.CountryCode, int(server.Distance), server.Name)
+	s.Location = loc
+	if vincenty {
+		s.Settings.UpdateDistancesWith(loc.Lat, loc.Lon, speedtest.VincentyDistance)
+	} else {
+		s.Settings.UpdateDistances(loc.Lat, loc.Lon)
+	}
+
+	fmt.Fprintf(out, "  ISP: %v\n", s.Config.Client.IspName)
+	if loc.Name != "" {
+		fmt.Fprintf(out, "  Location: %v, %v (%v, from %v)\n",
+			loc.Lat, loc.Lon, loc, source)
+	} else {
+		fmt.Fprintf(out, "  Location: %v, %v (from %v)\n", loc.Lat, loc.Lon, source)
+	}
+	printTimings(api.Trace.Timings())
+	fmt.Fprintln(out)
+
+	filter, err := serverFilter()
+	if err != nil {
+		fmt.Fprintf(out, "Invalid filter: %v\n", err)
+		os.Exit(2)
+	}
+	s.Filtered = s.Settings.Servers.Filter(filter)
+
+	if useHTTPS {
+		for i := range s.Settings.Servers {
+			s.Settings.Servers[i].URL = secure(s.Settings.Servers[i].URL)
+		}
+		for i := range s.Filtered {
+			s.Filtered[i].URL = secure(s.Filtered[i].URL)
+		}
+	}
+	return s
+}
+
+// newClient creates an HTTP client using the given network, or the network
+// selected by the command line flags if empty.
+func newClient(network string) (http.Client, error) {
+	var proxy *url.URL
+	if proxyURL != "" {
+		var err error
+		if proxy, err = url.Parse(proxyURL); err != nil {
+			return http.Client{}, err
+		}
+	}
+
+	if network == "" {
+		if forceIPv4 {
+			network = speedtest.IPv4
+		} else if forceIPv6 {
+			network = speedtest.IPv6
 		}
+	}
+	versions := map[string]string{
+		"":    "",
+		"1.1": speedtest.HTTP1,
+		"2":   speedtest.HTTP2,
+	}
+	version, ok := versions[httpVersion]
+	if !ok {
+		return http.Client{}, fmt.Errorf("unknown HTTP version %q", httpVersion)
+	}
+
+	return speedtest.NewClient(speedtest.ClientOptions{
+		Timeout:     httpTimeout,
+		Network:     network,
+		LocalAddr:   sourceAddr,
+		Interface:   sourceInterface,
+		Proxy:       proxy,
+		HTTPVersion: version,
+		TLSConfig:   &tls.Config{InsecureSkipVerify: insecure},
+	})
+}
+
+// latencySummary formats the percentiles of the given latencies.
+func latencySummary(l speedtest.Latencies) string {
+	return fmt.Sprintf("p50 %v, p90 %v, p99 %v",
+		l.Percentile(50).Round(time.Millisecond/10),
+		l.Percentile(90).Round(time.Millisecond/10),
+		l.Percentile(99).Round(time.Millisecond/10))
+}
+
+// printTimings prints percentiles of each request phase, if verbose.
+func printTimings(t speedtest.PhaseTimings) {
+	if !verbose {
 		return
 	}
+	phases := []struct {
+		name      string
+		latencies speedtest.Latencies
+	}{
+		{"DNS", t.DNS},
+		{"Connect", t.Connect},
+		{"TLS", t.TLS},
+		{"TTFB", t.TTFB},
+		{"Transfer", t.Transfer},
+	}
+	for _, phase := range phases {
+		if len(phase.latencies) == 0 {
+			continue
+		}
+		fmt.Fprintf(out, "  %v: %v (%d requests)\n", phase.name,
+			latencySummary(phase.latencies), len(phase.latencies))
+	}
+}
 
-	var server speedtest.Server
-	switch sampleServer {
-	case findNearest:
-		settings.Servers.SortByDistance()
-		server = settings.Servers[0]
-	case findFarthest:
-		settings.Servers.SortByDistance()
-		server = settings.Servers[len(settings.Servers)-1]
-	default:
-		// find server with ID
-		for _, s := range settings.Servers {
-			if s.ID == sampleServer {
-				server = s
-				break
-			}
+// cache returns the cache to use for API responses, if caching is enabled.
+func cache() (speedtest.Cache, bool) {
+	if noCache {
+		return speedtest.Cache{}, false
+	}
+	dir, err := speedtest.DefaultCacheDir()
+	if err != nil {
+		fmt.Fprintf(out, "warning: not caching: %v\n", err)
+		return speedtest.Cache{}, false
+	}
+	return speedtest.Cache{Dir: dir, TTL: cacheTTL}, true
+}
+
+// fetchSettings fetches the server list, from the cache if enabled. A
+// warning is printed if the cached list is stale.
+func fetchSettings(api speedtest.API) (speedtest.Settings, error) {
+	c, ok := cache()
+	if !ok {
+		return api.FetchSettings()
+	}
+	settings, err := api.FetchSettingsCached(c)
+	if stale, ok := err.(*speedtest.StaleError); ok {
+		fmt.Fprintf(out, "warning: %v\n", stale)
+		err = nil
+	}
+	return settings, err
+}
+
+// loadSettings fetches the server list and loads any servers from
+// -servers-file, printing progress.
+func loadSettings(api speedtest.API) (speedtest.Settings, error) {
+	var settings speedtest.Settings
+	if serversFileOnly && serversFile == "" {
+		return settings, fmt.Errorf("-servers-file-only requires -servers-file")
+	}
+
+	if !serversFileOnly {
+		fmt.Fprintf(out, "Fetching server list... ")
+		var err error
+		if settings, err = fetchSettings(api); err != nil {
+			return settings, err
 		}
+		fmt.Fprintf(out, "%v found.\n", len(settings.Servers))
 	}
 
-	if server.ID == 0 {
-		fmt.Printf("Could not find server. Re-run with -list-servers for a list.\n")
-		os.Exit(1)
+	if serversFile != "" {
+		fmt.Fprintf(out, "Loading servers from %v... ", serversFile)
+		local, err := loadServersFile(serversFile)
+		if err != nil {
+			return settings, err
+		}
+		settings.Servers = settings.Servers.Merge(local.Servers)
+		fmt.Fprintf(out, "%v found.\n", len(local.Servers))
+	}
+
+	return settings, nil
+}
+
+// loadServersFile loads server definitions from a local file, as by
+// speedtest.LoadSettings, or from YAML if it has a .yaml or .yml extension.
+func loadServersFile(path string) (speedtest.Settings, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		settings := speedtest.Settings{}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return settings, err
+		}
+		if err := yaml.Unmarshal(data, &settings); err != nil {
+			return settings, fmt.Errorf("%v: %v", path, err)
+		}
+		return settings, nil
+	default:
+		return speedtest.LoadSettings(path)
+	}
+}
+
+// clientLocation returns the location of the client, and where it came from,
+// preferring -location, then -geoip-db, then the API config.
+func clientLocation(config speedtest.Config) (speedtest.Location, string, error) {
+	if location != "" {
+		loc, err := speedtest.ParseLocation(location)
+		return loc, "-location", err
+	}
+	if geoIPDatabase != "" {
+		ip := net.ParseIP(config.Client.IPAddress)
+		loc, err := geoip.Lookup(geoIPDatabase, ip)
+		return loc, "GeoIP database", err
+	}
+	return speedtest.Location{
+		Lat: config.Client.Lat,
+		Lon: config.Client.Lon,
+	}, "API", nil
+}
+
+// fetchConfig fetches the client config, falling back to the cache if
+// enabled. A warning is printed if the cached config is used.
+func fetchConfig(api speedtest.API) (speedtest.Config, error) {
+	c, ok := cache()
+	if !ok {
+		return api.FetchConfig()
+	}
+	config, err := api.FetchConfigCached(c)
+	if stale, ok := err.(*speedtest.StaleError); ok {
+		fmt.Fprintf(out, "warning: %v\n", stale)
+		err = nil
+	}
+	return config, err
+}
+
+// secure returns the given URL with its scheme changed to HTTPS.
+func secure(url string) string {
+	if strings.HasPrefix(url, "http://") {
+		return "https://" + strings.TrimPrefix(url, "http://")
 	}
+	return url
+}
 
-	fmt.Printf("Using server %d. %v, %v, %v (%dkm)\n",
-		server.ID, server.Sponsor, server.Name, server.Country, int(server.Distance))
+// usedProxy returns the proxy given on the command line, or otherwise by the
+// environment, if any.
+func usedProxy() *url.URL {
+	if proxyURL != "" {
+		proxy, _ := url.Parse(proxyURL)
+		return proxy
+	}
+	req, _ := http.NewRequest("GET", speedtest.DefaultSettingsURL, nil)
+	proxy, _ := http.ProxyFromEnvironment(req)
+	return proxy
+}
 
-	client := http.Client{
-		Transport: &http.Transport{
-			Dial: func(network, addr string) (net.Conn, error) {
-				return net.DialTimeout(network, addr, httpTimeout)
-			},
-		},
+// serverFilter creates a server filter from the command line flags.
+func serverFilter() (speedtest.ServerFilter, error) {
+	filter := speedtest.ServerFilter{
+		CountryCode: filterCountry,
+		Sponsor:     filterSponsor,
+		MaxDistance: filterDistance,
+	}
+	if filterName != "" {
+		name, err := regexp.Compile(filterName)
+		if err != nil {
+			return filter, err
+		}
+		filter.Name = name
+	}
+	if filterExclude != "" {
+		for _, field := range strings.Split(filterExclude, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil {
+				return filter, fmt.Errorf("invalid server id %q", field)
+			}
+			filter.ExcludeIDs = append(filter.ExcludeIDs, id)
+		}
 	}
+	return filter, nil
+}
 
-	if testDownload {
-		benchmark := speedtest.NewDownloadBenchmark(client, server)
-		fmt.Print("Testing download speed... ")
-		rate := speedtest.RunBenchmark(benchmark, sampleThreads, sampleMaxThreads, samplePeriod)
-		fmt.Println(speedtest.NiceRate(rate))
+// selectServer chooses a single server according to -server.
+func selectServer(s session) (speedtest.Server, error) {
+	switch sampleServer {
+	case findNearest, findFarthest:
+		if len(s.Filtered) == 0 {
+			return speedtest.Server{}, fmt.Errorf("no servers match filters")
+		}
+		s.Filtered.SortByDistance()
+		if sampleServer == findNearest {
+			return s.Filtered[0], nil
+		}
+		return s.Filtered[len(s.Filtered)-1], nil
+	default:
+		server, ok := findServer(s.Settings.Servers, sampleServer)
+		if !ok {
+			return server, fmt.Errorf("could not find server %d", sampleServer)
+		}
+		return server, nil
 	}
+}
 
-	if testUpload {
-		benchmark := speedtest.NewUploadBenchmark(client, server)
-		fmt.Printf("Testing upload speed... ")
-		rate := speedtest.RunBenchmark(benchmark, sampleThreads, sampleMaxThreads, samplePeriod)
-		fmt.Println(speedtest.NiceRate(rate))
+// findServer finds the server with the given ID.
+func findServer(servers speedtest.Servers, id int) (speedtest.Server, bool) {
+	for _, s := range servers {
+		if s.ID == id {
+			return s, true
+		}
 	}
+	return speedtest.Server{}, false
 }
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"github.com/johnsto/speedtest"
+	. "github.com/smartystreets/goconvey/convey"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_SelectServers(t *testing.T) {
+	defer func(server, multi int) {
+		sampleServer, multiServer = server, multi
+	}(sampleServer, multiServer)
+
+	servers := speedtest.Servers{
+		{ID: 1, Distance: 300, CountryCode: "GB"},
+		{ID: 2, Distance: 100, CountryCode: "FR"},
+		{ID: 3, Distance: 200, CountryCode: "GB"},
+	}
+	s := session{Settings: speedtest.Settings{Servers: servers}}
+
+	Convey("Multiple servers should be the nearest that match the filters", t, func() {
+		s.Filtered = append(speedtest.Servers(nil), servers...)
+		sampleServer, multiServer = 1, 2
+		selected, err := selectServers(s)
+		So(err, ShouldBeNil)
+		So(selected, ShouldHaveLength, 2)
+		So(selected[0].ID, ShouldEqual, 2)
+		So(selected[1].ID, ShouldEqual, 3)
+
+		s.Filtered = servers.Filter(speedtest.ServerFilter{CountryCode: "GB"})
+		sampleServer, multiServer = findFarthest, 5
+		selected, err = selectServers(s)
+		So(err, ShouldBeNil)
+		So(selected, ShouldHaveLength, 2)
+		So(selected[0].ID, ShouldEqual, 3)
+	})
+
+	Convey("Selection should fail when no servers match the filters", t, func() {
+		s.Filtered = nil
+		for _, multi := range []int{1, 3} {
+			sampleServer, multiServer = findNearest, multi
+			_, err := selectServers(s)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
+
+func Test_ParseCommand(t *testing.T) {
+	Convey("Commands should be chosen by name, defaulting to run", t, func() {
+		cmd, args, ok := parseCommand(nil)
+		So(ok, ShouldBeTrue)
+		So(cmd.name, ShouldEqual, "run")
+		So(args, ShouldBeEmpty)
+
+		cmd, args, ok = parseCommand([]string{"-threads", "2"})
+		So(ok, ShouldBeTrue)
+		So(cmd.name, ShouldEqual, "run")
+		So(args, ShouldResemble, []string{"-threads", "2"})
+
+		cmd, args, ok = parseCommand([]string{"servers", "-limit", "3"})
+		So(ok, ShouldBeTrue)
+		So(cmd.name, ShouldEqual, "servers")
+		So(args, ShouldResemble, []string{"-limit", "3"})
+
+		_, _, ok = parseCommand([]string{"speed"})
+		So(ok, ShouldBeFalse)
+	})
+
+	Convey("Each command should parse its own flags", t, func() {
+		for _, c := range commands {
+			fs := newFlagSet(c)
+			So(fs.Lookup("config"), ShouldNotBeNil)
+		}
+
+		cmd, args, _ := parseCommand([]string{"servers", "-sort", "id", "-limit", "3"})
+		So(newFlagSet(cmd).Parse(args), ShouldBeNil)
+		So(serversSort, ShouldEqual, "id")
+		So(serversLimit, ShouldEqual, 3)
+
+		cmd, args, _ = parseCommand([]string{"-history", "-repeat", "3"})
+		So(newFlagSet(cmd).Parse(args), ShouldBeNil)
+		So(saveHistory, ShouldBeTrue)
+		So(repeat, ShouldEqual, 3)
+
+		cmd, _, _ = parseCommand([]string{"ping"})
+		So(newFlagSet(cmd).Lookup("repeat"), ShouldBeNil)
+	})
+}
+
+func Test_Truncate(t *testing.T) {
+	Convey("Names should be truncated by character", t, func() {
+		So(truncate("London", 24), ShouldEqual, "London")
+		So(truncate("São Paulo – Centro", 10), ShouldEqual, "São Paulo…")
+		So([]rune(truncate("ÅÅÅÅÅÅÅÅÅÅÅÅ", 5)), ShouldHaveLength, 5)
+	})
+}
+
+func Test_History(t *testing.T) {
+	defer func(f string, w io.Writer) { historyFile, out = f, w }(historyFile, out)
+	historyFile = filepath.Join(t.TempDir(), "history.jsonl")
+	historyLimit, jsonOutput = 0, false
+
+	ms := time.Millisecond
+	single := results{
+		Latency:  speedtest.Latencies{10 * ms},
+		Download: &speedtest.BenchmarkResult{Rate: 1250000},
+		Upload:   &speedtest.BenchmarkResult{Rate: 125000},
+	}
+	slow := single
+	slow.Download = &speedtest.BenchmarkResult{Rate: 250000}
+	repeated := record{Trials: []results{single, slow, single}}
+	repeated.Summary = summarize(repeated.Trials)
+
+	Convey("History should show each metric in its column", t, func() {
+		So(appendHistory(record{results: single}), ShouldBeNil)
+		So(appendHistory(repeated), ShouldBeNil)
+
+		var buf bytes.Buffer
+		out = &buf
+		historyCommand(nil)
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		So(lines, ShouldHaveLength, 3)
+		for _, line := range lines[1:] {
+			fields := strings.Fields(line)
+			So(fields[len(fields)-3:], ShouldResemble,
+				[]string{"10ms", "10.00Mbps", "1.00Mbps"})
+		}
+	})
+
+	Convey("Unknown metrics should be shown as missing", t, func() {
+		So(historyValue(record{results: single}, "jitter"), ShouldEqual, "-")
+		So(historyValue(repeated, "jitter"), ShouldEqual, "-")
+	})
+}
+
+func Test_PrintComparison(t *testing.T) {
+	defer func(w io.Writer) { out = w }(out)
+
+	v4 := results{
+		Download: &speedtest.BenchmarkResult{Rate: 1250000},
+		Upload:   &speedtest.BenchmarkResult{Rate: 125000},
+	}
+
+	Convey("Bidirectional rows should be shown only if measured", t, func() {
+		var buf bytes.Buffer
+		out = &buf
+		printComparison("IPv4", "IPv6", v4, results{})
+		So(buf.String(), ShouldContainSubstring, "Download")
+		So(buf.String(), ShouldNotContainSubstring, "Bidi")
+
+		buf.Reset()
+		v4.BidiDownload = &speedtest.BenchmarkResult{Rate: 625000}
+		v4.BidiUpload = &speedtest.BenchmarkResult{Rate: 62500}
+		printComparison("IPv4", "IPv6", v4, results{})
+		So(buf.String(), ShouldContainSubstring, "Bidi download")
+		So(buf.String(), ShouldContainSubstring, "Bidi upload")
+		So(buf.String(), ShouldContainSubstring, niceRate(625000))
+	})
+}
+
+func Test_LoadServersFile(t *testing.T) {
+	Convey("Servers should load from YAML", t, func() {
+		settings, err := loadServersFile(writeConfig(t, "servers.yaml", `
+servers:
+  - id: 8
+    url: http://c.example/speedtest/upload.php
+    cc: DE
+    name: Berlin
+`))
+		So(err, ShouldBeNil)
+		So(len(settings.Servers), ShouldEqual, 1)
+		So(settings.Servers[0].Name, ShouldEqual, "Berlin")
+		So(settings.Servers[0].CountryCode, ShouldEqual, "DE")
+
+		_, err = loadServersFile(writeConfig(t, "bad.yml", "servers: {"))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Other formats should be loaded by the library", t, func() {
+		settings, err := loadServersFile(writeConfig(t, "servers.json",
+			`{"servers": [{"id": 7, "name": "Paris"}]}`))
+		So(err, ShouldBeNil)
+		So(settings.Servers[0].Name, ShouldEqual, "Paris")
+
+		_, err = loadServersFile(writeConfig(t, "servers.txt", ""))
+		So(err, ShouldNotBeNil)
+	})
+}
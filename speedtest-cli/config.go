@@ -0,0 +1,321 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2014 David Johnston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// envPrefix prefixes the names of environment variables setting flags.
+const envPrefix = "SPEEDTEST_"
+
+// configFile is the configuration file given by -config.
+var configFile string
+
+// configFlag registers the -config flag.
+func configFlag(fs *flag.FlagSet) {
+	fs.StringVar(&configFile, "config", "",
+		"Configuration file (.toml, .yaml or .json), otherwise searched for "+
+			"in the XDG config directories")
+}
+
+// configSources records where the value of each flag came from.
+type configSources map[string]string
+
+// configure sets each flag not given on the command line from its
+// environment variable, or otherwise the configuration file, returning the
+// source of each flag's value. Flags of all commands are given by known,
+// against which the file's settings are checked.
+func configure(fs *flag.FlagSet, cmd string, known map[string]bool) (configSources, error) {
+	sources := configSources{}
+	fs.Visit(func(f *flag.Flag) { sources[f.Name] = "flag" })
+
+	path := configFile
+	if path == "" {
+		path = os.Getenv(envPrefix + "CONFIG")
+	}
+	if path == "" {
+		path = findConfigFile()
+	}
+	if path != "" {
+		settings, err := loadConfig(path, cmd, known)
+		if err != nil {
+			return sources, err
+		}
+		for name, value := range settings {
+			if fs.Lookup(name) == nil || sources[name] != "" {
+				continue
+			}
+			if err := fs.Set(name, value); err != nil {
+				return sources, fmt.Errorf("%v: %v: %v", path, name, err)
+			}
+			sources[name] = path
+		}
+	}
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		env := envName(f.Name)
+		value, ok := os.LookupEnv(env)
+		if !ok || sources[f.Name] == "flag" || err != nil {
+			return
+		}
+		if err = fs.Set(f.Name, value); err != nil {
+			err = fmt.Errorf("%v: %v", env, err)
+			return
+		}
+		sources[f.Name] = env
+	})
+	return sources, err
+}
+
+// envName returns the environment variable setting the named flag.
+func envName(flag string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(flag, "-", "_", -1))
+}
+
+// findConfigFile searches the XDG config directories for a configuration
+// file, returning an empty string if there is none.
+func findConfigFile() string {
+	var dirs []string
+	if dir, err := os.UserConfigDir(); err == nil {
+		dirs = append(dirs, dir)
+	}
+	xdgDirs := os.Getenv("XDG_CONFIG_DIRS")
+	if xdgDirs == "" {
+		xdgDirs = "/etc/xdg"
+	}
+	dirs = append(dirs, filepath.SplitList(xdgDirs)...)
+
+	for _, dir := range dirs {
+		for _, name := range []string{"config.toml", "config.yaml",
+			"config.yml", "config.json"} {
+			path := filepath.Join(dir, "speedtest", name)
+			if _, err := os.Stat(path); err == nil {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// loadConfig reads the flag values set by a configuration file for the
+// given command. Top-level settings apply to every command with a flag of
+// that name, and are overridden by settings in a table named after the
+// command, for example:
+//
+//	threads = 8
+//	period = "15s"
+//
+//	[servers]
+//	sort = "id"
+//
+// A table named after a command is always taken to be a command's settings,
+// so the run command's -servers flag must be set within [run].
+func loadConfig(path, cmd string, known map[string]bool) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]interface{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".toml":
+		err = toml.Unmarshal(data, &doc)
+	case ".json":
+		err = json.Unmarshal(data, &doc)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	default:
+		return nil, fmt.Errorf("unknown configuration format %q", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+
+	settings := map[string]string{}
+	var section map[string]interface{}
+	for key, value := range doc {
+		if table, ok := stringMap(value); ok && isCommand(key) {
+			if key == cmd {
+				section = table
+			}
+			continue
+		}
+		if !known[key] {
+			return nil, fmt.Errorf("%v: unknown setting %q", path, key)
+		}
+		settings[key] = configValue(value)
+	}
+	for key, value := range section {
+		if !known[key] {
+			return nil, fmt.Errorf("%v: unknown setting %q in [%v]",
+				path, key, cmd)
+		}
+		settings[key] = configValue(value)
+	}
+	return settings, nil
+}
+
+// stringMap converts a table decoded from a configuration file to a map.
+func stringMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		// As decoded from YAML
+		table := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			table[fmt.Sprint(k)] = v
+		}
+		return table, true
+	}
+	return nil, false
+}
+
+// configValue formats a value decoded from a configuration file as a flag
+// value. Lists are joined by commas, and numbers are written without
+// exponents, as JSON decodes even integers as floats.
+func configValue(v interface{}) string {
+	switch v := v.(type) {
+	case []interface{}:
+		values := make([]string, len(v))
+		for i, item := range v {
+			values[i] = configValue(item)
+		}
+		return strings.Join(values, ",")
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// isCommand returns true if the name is that of a command.
+func isCommand(name string) bool {
+	for _, c := range commands {
+		if c.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// knownFlags returns the names of the flags of every command. It must be
+// called before parsing, as registering flags resets them to defaults.
+func knownFlags() map[string]bool {
+	known := map[string]bool{}
+	for _, c := range commands {
+		fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+		c.flags(fs)
+		fs.VisitAll(func(f *flag.Flag) { known[f.Name] = true })
+	}
+	return known
+}
+
+// configCommandFlags registers the flags of the config command.
+func configCommandFlags(fs *flag.FlagSet) {}
+
+// configCommand prints the effective configuration of a command, given as
+// 'config show [command] [flags]', and where each value came from.
+func configCommand(fs *flag.FlagSet) {
+	args := fs.Args()
+	if len(args) == 0 || args[0] != "show" {
+		fmt.Fprintln(os.Stderr, "Usage: speedtest-cli config show [command] [flags]")
+		os.Exit(2)
+	}
+	args = args[1:]
+
+	cmd := commands[0]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		if !isCommand(args[0]) || args[0] == "config" {
+			fmt.Fprintf(os.Stderr, "Unknown command %q\n", args[0])
+			os.Exit(2)
+		}
+		for _, c := range commands {
+			if c.name == args[0] {
+				cmd = c
+			}
+		}
+		args = args[1:]
+	}
+
+	// Registering -config on the command's flags resets it, so carry over
+	// any given before 'show' unless the command's flags give another
+	parent := configFile
+	known := knownFlags()
+	target := newFlagSet(cmd)
+	if parent != "" {
+		target.Set("config", parent)
+	}
+	target.Parse(args)
+	sources, err := configure(target, cmd.name, known)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		os.Exit(2)
+	}
+
+	var names []string
+	target.VisitAll(func(f *flag.Flag) { names = append(names, f.Name) })
+	sort.Strings(names)
+	fmt.Fprintf(out, "# Configuration of '%v'\n", cmd.name)
+	for _, name := range names {
+		f := target.Lookup(name)
+		source := sources[name]
+		if source == "" {
+			source = "default"
+		}
+		fmt.Fprintf(out, "%-40v # %v\n",
+			fmt.Sprintf("%v = %v", tomlKey(name), tomlValue(f)), source)
+	}
+}
+
+// tomlKey formats a flag name as a TOML key.
+func tomlKey(name string) string {
+	if strings.Trim(name, "0123456789") == "" {
+		return fmt.Sprintf("%q", name)
+	}
+	return name
+}
+
+// tomlValue formats the value of a flag as a TOML value.
+func tomlValue(f *flag.Flag) string {
+	if getter, ok := f.Value.(flag.Getter); ok {
+		switch v := getter.Get().(type) {
+		case bool, int, int64, uint, uint64, float64:
+			return fmt.Sprint(v)
+		}
+	}
+	return fmt.Sprintf("%q", f.Value.String())
+}
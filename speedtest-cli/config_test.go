@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	. "github.com/smartystreets/goconvey/convey"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeConfig writes a configuration file to a temporary directory.
+func writeConfig(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// parseRun parses the flags of the run command and applies configuration.
+func parseRun(args ...string) (configSources, error) {
+	known := knownFlags()
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	runFlags(fs)
+	configFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return configure(fs, "run", known)
+}
+
+// isolateConfig points the configuration directories at empty temporary
+// directories for the duration of the test.
+func isolateConfig(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_DIRS", t.TempDir())
+}
+
+const testConfig = `
+threads = 8
+period = "15s"
+sort = "id"
+
+[run]
+max-bytes = "50MB"
+servers = [1234, 5678]
+
+[servers]
+limit = 5
+`
+
+func Test_Configure(t *testing.T) {
+	isolateConfig(t)
+	toml := writeConfig(t, "config.toml", testConfig)
+	t.Setenv(envName("period"), "20s")
+	t.Setenv(envName("https"), "true")
+
+	Convey("Flags should override the environment, which overrides the file", t, func() {
+		sources, err := parseRun("-config", toml, "-threads", "2")
+		So(err, ShouldBeNil)
+		So(sampleThreads, ShouldEqual, 2)
+		So(sources["threads"], ShouldEqual, "flag")
+		So(samplePeriod, ShouldEqual, 20*time.Second)
+		So(sources["period"], ShouldEqual, "SPEEDTEST_PERIOD")
+		So(useHTTPS, ShouldBeTrue)
+		So(sampleServers, ShouldEqual, "1234,5678")
+		So(sources["servers"], ShouldEqual, toml)
+		So(maxBytes.bytes, ShouldEqual, 50000000)
+		So(sampleMaxThreads, ShouldEqual, 16)
+		So(sources["max-threads"], ShouldEqual, "")
+	})
+}
+
+func Test_ConfigureEnvFile(t *testing.T) {
+	isolateConfig(t)
+	yaml := writeConfig(t, "config.yaml", "threads: 3\nrun:\n  threads: 6\n")
+	t.Setenv(envName("config"), yaml)
+
+	Convey("The config file may be given by the environment", t, func() {
+		_, err := parseRun()
+		So(err, ShouldBeNil)
+		So(sampleThreads, ShouldEqual, 6)
+	})
+}
+
+func Test_ConfigureXDG(t *testing.T) {
+	isolateConfig(t)
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_DIRS", dir)
+
+	Convey("The config file should be found in the XDG config directories", t, func() {
+		So(os.MkdirAll(filepath.Join(dir, "speedtest"), 0755), ShouldBeNil)
+		So(ioutil.WriteFile(filepath.Join(dir, "speedtest", "config.json"),
+			[]byte(`{"threads": 5}`), 0644), ShouldBeNil)
+
+		_, err := parseRun()
+		So(err, ShouldBeNil)
+		So(sampleThreads, ShouldEqual, 5)
+	})
+}
+
+func Test_ConfigureJSON(t *testing.T) {
+	isolateConfig(t)
+
+	Convey("Large JSON numbers should be read as integers", t, func() {
+		path := writeConfig(t, "config.json",
+			`{"max-bytes": 50000000, "threads": 1000000, "converge-threshold": 0.025}`)
+		_, err := parseRun("-config", path)
+		So(err, ShouldBeNil)
+		So(maxBytes.bytes, ShouldEqual, 50000000)
+		So(sampleThreads, ShouldEqual, 1000000)
+		So(convergeLimit, ShouldEqual, 0.025)
+	})
+}
+
+func Test_ConfigureInvalid(t *testing.T) {
+	isolateConfig(t)
+
+	Convey("Unknown settings and invalid values should be rejected", t, func() {
+		_, err := parseRun("-config", writeConfig(t, "bad.toml", "thread = 8\n"))
+		So(err, ShouldNotBeNil)
+		_, err = parseRun("-config", writeConfig(t, "bad.json", `{"threads": "x"}`))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Invalid values in the environment should be rejected", t, func() {
+		t.Setenv(envName("period"), "soon")
+		_, err := parseRun("-config", writeConfig(t, "config.toml", testConfig))
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func Test_ConfigShow(t *testing.T) {
+	isolateConfig(t)
+	defer func(w io.Writer) { out = w }(out)
+	toml := writeConfig(t, "config.toml", testConfig)
+
+	Convey("The config file given before 'show' should be used", t, func() {
+		fs := flag.NewFlagSet("config", flag.ContinueOnError)
+		configCommandFlags(fs)
+		configFlag(fs)
+		So(fs.Parse([]string{"-config", toml, "show", "run"}), ShouldBeNil)
+
+		var buf bytes.Buffer
+		out = &buf
+		configCommand(fs)
+		So(buf.String(), ShouldContainSubstring, "# Configuration of 'run'")
+		So(buf.String(), ShouldContainSubstring, "# "+toml)
+		So(buf.String(), ShouldContainSubstring, "threads = 8")
+	})
+}
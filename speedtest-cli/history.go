@@ -0,0 +1,196 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2014 David Johnston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/johnsto/speedtest"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	historyFile  string
+	historyLimit int
+)
+
+// record is the outcome of a run, as saved to the history file.
+type record struct {
+	Time     time.Time          `json:"time"`
+	ISP      string             `json:"isp,omitempty"`
+	IP       string             `json:"ip,omitempty"`
+	Proxy    string             `json:"proxy,omitempty"`
+	Location speedtest.Location `json:"location"`
+	Servers  speedtest.Servers  `json:"servers"`
+	results
+	// Trials holds the results of each run, if the tests were repeated.
+	Trials []results `json:"trials,omitempty"`
+	// Summary summarizes each metric over the trials, by name.
+	Summary map[string]speedtest.Summary `json:"summary,omitempty"`
+}
+
+// historyFileFlag registers the -history-file flag.
+func historyFileFlag(fs *flag.FlagSet) {
+	fs.StringVar(&historyFile, "history-file", defaultHistoryFile(),
+		"File in which results are recorded")
+}
+
+// historyFlags registers the flags of the history command.
+func historyFlags(fs *flag.FlagSet) {
+	historyFileFlag(fs)
+	unitsFlag(fs)
+	fs.IntVar(&historyLimit, "limit", 20,
+		"Number of most recent results to show (0: all)")
+	fs.BoolVar(&jsonOutput, "json", false,
+		"Write the results as JSON lines")
+}
+
+// defaultHistoryFile returns the history file in the XDG data directory.
+func defaultHistoryFile() string {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dir, "speedtest", "history.jsonl")
+}
+
+// appendHistory appends the record to the history file as a JSON line.
+func appendHistory(rec record) error {
+	if historyFile == "" {
+		return fmt.Errorf("no history file")
+	}
+	if err := os.MkdirAll(filepath.Dir(historyFile), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(historyFile,
+		os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(rec); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// loadHistory reads every record from the history file.
+func loadHistory() ([]record, error) {
+	f, err := os.Open(historyFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []record
+	scanner := bufio.NewScanner(f)
+	// Sample series make for long lines
+	scanner.Buffer(nil, 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return records, fmt.Errorf("%v:%d: %v", historyFile, line, err)
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+// historyCommand prints previously recorded results.
+func historyCommand(fs *flag.FlagSet) {
+	records, err := loadHistory()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Couldn't read history: %v\n", err)
+		os.Exit(1)
+	}
+	if historyLimit > 0 && len(records) > historyLimit {
+		records = records[len(records)-historyLimit:]
+	}
+
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		for _, rec := range records {
+			enc.Encode(rec)
+		}
+		return
+	}
+
+	if len(records) == 0 {
+		fmt.Fprintf(out, "No results recorded in %v (see 'run -history')\n",
+			historyFile)
+		return
+	}
+	fmt.Fprintf(out, "%-16v %-24v %10v %14v %14v\n",
+		"Time", "Server", "Latency", "Download", "Upload")
+	for _, rec := range records {
+		server := "-"
+		if len(rec.Servers) == 1 {
+			server = fmt.Sprintf("%d. %v", rec.Servers[0].ID, rec.Servers[0].Name)
+		} else if len(rec.Servers) > 1 {
+			server = fmt.Sprintf("%d servers", len(rec.Servers))
+		}
+		fmt.Fprintf(out, "%-16v %-24v %10v %14v %14v\n",
+			rec.Time.Local().Format("2006-01-02 15:04"), truncate(server, 24),
+			historyValue(rec, "latency"), historyValue(rec, "download"),
+			historyValue(rec, "upload"))
+	}
+}
+
+// truncate shortens s to at most n characters, ending it with an ellipsis if
+// it was too long.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// historyValue formats the metric with the given key of a record, or its
+// median over repeated trials, if it is known and was measured.
+func historyValue(rec record, key string) string {
+	m, ok := findMetric(key)
+	if !ok {
+		return "-"
+	}
+	if len(rec.Trials) > 0 {
+		if s, ok := rec.Summary[m.key]; ok {
+			return m.format(s.Median)
+		}
+	} else if v, ok := m.value(rec.results); ok {
+		return m.format(v)
+	}
+	return "-"
+}
@@ -0,0 +1,90 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2014 David Johnston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/johnsto/speedtest"
+	"os"
+	"time"
+)
+
+var (
+	pingCount    int
+	pingInterval time.Duration
+)
+
+// pingFlags registers the flags of the ping command.
+func pingFlags(fs *flag.FlagSet) {
+	fs.IntVar(&pingCount, "count", 10, "Number of pings to send")
+	fs.DurationVar(&pingInterval, "interval", time.Second,
+		"Time between pings")
+	fs.IntVar(&sampleServer, "server", findNearest,
+		"Server id to ping (-1: use nearest, -2: use farthest)")
+	serverListFlags(fs)
+	connectionFlags(fs)
+}
+
+// pingCommand measures the latency to a server.
+func pingCommand(fs *flag.FlagSet) {
+	s := connect()
+	server, err := selectServer(s)
+	if err != nil {
+		fmt.Fprintf(out, "Couldn't select server: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(out, "Pinging server %d. %v, %v, %v (%dkm)\n",
+		server.ID, server.Sponsor, server.Name, server.Country,
+		int(server.Distance))
+
+	pinger := speedtest.NewPinger(s.Client, server)
+	var latencies speedtest.Latencies
+	failed := 0
+	for i := 0; i < pingCount; i++ {
+		if i > 0 {
+			time.Sleep(pingInterval)
+		}
+		latency, err := pinger.Ping()
+		if err != nil {
+			fmt.Fprintf(out, "  %d: error: %v\n", i+1, err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(out, "  %d: %v\n", i+1, latency.Round(time.Millisecond/10))
+		latencies = append(latencies, latency)
+	}
+
+	fmt.Fprintf(out, "%d sent, %d failed\n", pingCount, failed)
+	if len(latencies) > 0 {
+		fmt.Fprintf(out, "Latency: min %v, %v, max %v\n",
+			latencies.Percentile(0).Round(time.Millisecond/10),
+			latencySummary(latencies),
+			latencies.Percentile(100).Round(time.Millisecond/10))
+	}
+	if failed == pingCount {
+		os.Exit(1)
+	}
+}
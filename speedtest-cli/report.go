@@ -0,0 +1,482 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2014 David Johnston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/johnsto/speedtest"
+	"html"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	reportInput string
+	reportHTML  string
+	reportSVG   string
+)
+
+// Chart dimensions
+const (
+	chartWidth  = 720
+	chartHeight = 300
+	chartLeft   = 60
+	chartRight  = 20
+	chartTop    = 30
+	chartBottom = 40
+)
+
+// reportFlags registers the flags of the report command.
+func reportFlags(fs *flag.FlagSet) {
+	fs.StringVar(&reportInput, "in", "",
+		"Result to report, as written by 'run -json' or the history file "+
+			"(-: stdin), otherwise the most recent in the history file")
+	historyFileFlag(fs)
+	unitsFlag(fs)
+	fs.StringVar(&reportHTML, "html", "speedtest-report.html",
+		"HTML file to write (-: stdout)")
+	fs.StringVar(&reportSVG, "svg", "",
+		"Standalone SVG throughput chart to write")
+}
+
+// reportCommand renders a saved result as HTML and SVG.
+func reportCommand(fs *flag.FlagSet) {
+	rec, err := readRecord(reportInput)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Couldn't read result: %v\n", err)
+		os.Exit(1)
+	}
+	if len(rec.Trials) > 0 {
+		// Chart the last of the repeated trials.
+		rec.results = rec.Trials[len(rec.Trials)-1]
+	}
+
+	if reportSVG != "" {
+		svg := throughputChart(rec)
+		if err := ioutil.WriteFile(reportSVG, []byte(svg), 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Couldn't write chart: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if reportHTML == "" {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := renderReport(&buf, rec); err != nil {
+		fmt.Fprintf(os.Stderr, "Couldn't render report: %v\n", err)
+		os.Exit(1)
+	}
+	if reportHTML == "-" {
+		os.Stdout.Write(buf.Bytes())
+	} else if err := ioutil.WriteFile(reportHTML, buf.Bytes(), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Couldn't write report: %v\n", err)
+		os.Exit(1)
+	} else {
+		fmt.Fprintf(out, "Wrote %v\n", reportHTML)
+	}
+}
+
+// readRecord reads a record from a JSON file, or the last record of a JSON
+// lines file. If path is empty, the last record in the history file is read.
+func readRecord(path string) (record, error) {
+	if path == "" {
+		records, err := loadHistory()
+		if err != nil {
+			return record{}, err
+		}
+		if len(records) == 0 {
+			return record{}, fmt.Errorf(
+				"no results recorded in %v (see 'run -history')", historyFile)
+		}
+		return records[len(records)-1], nil
+	}
+
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return record{}, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var rec record
+	dec := json.NewDecoder(r)
+	found := false
+	for {
+		var next record
+		if err := dec.Decode(&next); err == io.EOF {
+			break
+		} else if err != nil {
+			return rec, err
+		}
+		rec, found = next, true
+	}
+	if !found {
+		return rec, fmt.Errorf("no results in %v", path)
+	}
+	return rec, nil
+}
+
+// series is a named sequence of rates to chart.
+type series struct {
+	Name   string
+	Color  string
+	Dashed bool
+	Result *speedtest.BenchmarkResult
+}
+
+// recordSeries returns the throughput series measured in a record.
+func recordSeries(rec record) []series {
+	all := []series{
+		{"Download", "#1f77b4", false, rec.Download},
+		{"Upload", "#ff7f0e", false, rec.Upload},
+		{"Download (bidirectional)", "#1f77b4", true, rec.BidiDownload},
+		{"Upload (bidirectional)", "#ff7f0e", true, rec.BidiUpload},
+	}
+	var measured []series
+	for _, s := range all {
+		if s.Result != nil && len(s.Result.Samples) > 0 && s.Result.Resolution > 0 {
+			measured = append(measured, s)
+		}
+	}
+	return measured
+}
+
+// rollingRates converts samples to rates in Mbit/s, each averaged over the
+// preceding second.
+func rollingRates(r *speedtest.BenchmarkResult) []float64 {
+	n := int(time.Second / r.Resolution)
+	if n < 1 {
+		n = 1
+	}
+	rates := make([]float64, len(r.Samples))
+	sum := 0
+	for i, s := range r.Samples {
+		sum += s
+		if i >= n {
+			sum -= r.Samples[i-n]
+		}
+		width := n
+		if i+1 < n {
+			width = i + 1
+		}
+		seconds := (time.Duration(width) * r.Resolution).Seconds()
+		rates[i] = float64(sum) * 8 / 1e6 / seconds
+	}
+	return rates
+}
+
+// niceCeil rounds up to 1, 2 or 5 times a power of ten.
+func niceCeil(v float64) float64 {
+	if v <= 0 {
+		return 1
+	}
+	exp := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if v <= m*exp {
+			return m * exp
+		}
+	}
+	return 10 * exp
+}
+
+// throughputChart draws the throughput over time of each series as an SVG
+// document.
+func throughputChart(rec record) string {
+	all := recordSeries(rec)
+	plotW := float64(chartWidth - chartLeft - chartRight)
+	plotH := float64(chartHeight - chartTop - chartBottom)
+
+	maxT, maxRate := 1.0, 0.0
+	rates := make([][]float64, len(all))
+	for i, s := range all {
+		rates[i] = rollingRates(s.Result)
+		t := (time.Duration(len(s.Result.Samples)) * s.Result.Resolution).Seconds()
+		maxT = math.Max(maxT, t)
+		for _, r := range rates[i] {
+			maxRate = math.Max(maxRate, r)
+		}
+	}
+	maxRate = niceCeil(maxRate)
+	x := func(t float64) float64 { return chartLeft + t/maxT*plotW }
+	y := func(r float64) float64 { return chartTop + plotH - r/maxRate*plotH }
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" `+
+		`viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`+"\n",
+		chartWidth, chartHeight, chartWidth, chartHeight)
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+
+	// Horizontal grid lines and rate labels
+	for i := 0; i <= 5; i++ {
+		r := maxRate * float64(i) / 5
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#ddd"/>`+"\n",
+			chartLeft, y(r), chartWidth-chartRight, y(r))
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end" dy="4">%g</text>`+"\n",
+			chartLeft-6, y(r), r)
+	}
+	// Time labels
+	step := math.Max(1, niceCeil(maxT/10))
+	for t := 0.0; t <= maxT+1e-9; t += step {
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle">%gs</text>`+"\n",
+			x(t), chartHeight-chartBottom+16, t)
+	}
+	fmt.Fprintf(&b, `<text x="14" y="%d" transform="rotate(-90 14 %d)" `+
+		`text-anchor="middle">Mbit/s</text>`+"\n",
+		chartTop+int(plotH)/2, chartTop+int(plotH)/2)
+	fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#333"/>`+"\n",
+		chartLeft, y(0), chartWidth-chartRight, y(0))
+
+	// Series and legend
+	for i, s := range all {
+		res := s.Result.Resolution.Seconds()
+		points := make([]string, len(rates[i]))
+		for j, r := range rates[i] {
+			points[j] = fmt.Sprintf("%.1f,%.1f", x(float64(j+1)*res), y(r))
+		}
+		dash := ""
+		if s.Dashed {
+			dash = ` stroke-dasharray="6 4"`
+		}
+		fmt.Fprintf(&b, `<polyline fill="none" stroke="%v" stroke-width="2"%v points="%v"/>`+"\n",
+			s.Color, dash, strings.Join(points, " "))
+
+		lx := chartLeft + 10 + i*170
+		fmt.Fprintf(&b, `<line x1="%d" y1="14" x2="%d" y2="14" stroke="%v" stroke-width="2"%v/>`+"\n",
+			lx, lx+20, s.Color, dash)
+		fmt.Fprintf(&b, `<text x="%d" y="18">%v</text>`+"\n",
+			lx+25, html.EscapeString(s.Name))
+	}
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+// latencyChart draws a histogram of idle and loaded latencies as an SVG
+// document, or returns an empty string if no latency was measured.
+func latencyChart(rec record) string {
+	type dist struct {
+		name      string
+		color     string
+		latencies speedtest.Latencies
+	}
+	var all []dist
+	if len(rec.Latency) > 0 {
+		all = append(all, dist{"Idle", "#2ca02c", rec.Latency})
+	}
+	if rec.Download != nil && len(rec.Download.LoadedLatency) > 0 {
+		all = append(all, dist{"Loaded (download)", "#1f77b4", rec.Download.LoadedLatency})
+	}
+	if rec.Upload != nil && len(rec.Upload.LoadedLatency) > 0 {
+		all = append(all, dist{"Loaded (upload)", "#ff7f0e", rec.Upload.LoadedLatency})
+	}
+	if len(all) == 0 {
+		return ""
+	}
+
+	const bins = 20
+	maxLatency := 0.0
+	for _, d := range all {
+		for _, l := range d.latencies {
+			maxLatency = math.Max(maxLatency, float64(l)/float64(time.Millisecond))
+		}
+	}
+	maxLatency = niceCeil(maxLatency)
+	binWidth := maxLatency / bins
+
+	counts := make([][]float64, len(all))
+	maxShare := 0.0
+	for i, d := range all {
+		counts[i] = make([]float64, bins)
+		for _, l := range d.latencies {
+			bin := int(float64(l) / float64(time.Millisecond) / binWidth)
+			if bin >= bins {
+				bin = bins - 1
+			}
+			counts[i][bin] += 1 / float64(len(d.latencies))
+		}
+		for _, c := range counts[i] {
+			maxShare = math.Max(maxShare, c)
+		}
+	}
+
+	plotW := float64(chartWidth - chartLeft - chartRight)
+	plotH := float64(chartHeight - chartTop - chartBottom)
+	barW := plotW / bins
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" `+
+		`viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`+"\n",
+		chartWidth, chartHeight, chartWidth, chartHeight)
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	for i, d := range all {
+		for j, c := range counts[i] {
+			if c == 0 {
+				continue
+			}
+			h := c / maxShare * plotH
+			fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" `+
+				`fill="%v" fill-opacity="0.5"/>`+"\n",
+				chartLeft+float64(j)*barW, chartTop+plotH-h, barW-1, h, d.color)
+		}
+		lx := chartLeft + 10 + i*170
+		fmt.Fprintf(&b, `<rect x="%d" y="8" width="12" height="12" fill="%v" fill-opacity="0.5"/>`+"\n",
+			lx, d.color)
+		fmt.Fprintf(&b, `<text x="%d" y="18">%v</text>`+"\n",
+			lx+18, html.EscapeString(d.name))
+	}
+	fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#333"/>`+"\n",
+		chartLeft, chartTop+plotH, chartWidth-chartRight, chartTop+plotH)
+	for i := 0; i <= 4; i++ {
+		l := maxLatency * float64(i) / 4
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle">%gms</text>`+"\n",
+			chartLeft+plotW*float64(i)/4, chartHeight-chartBottom+16, l)
+	}
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+// reportRow is a row of the estimates table.
+type reportRow struct {
+	Name, Rate, Latency, Bufferbloat, Details string
+}
+
+// estimateRows tabulates the estimated rates of each test.
+func estimateRows(rec record) []reportRow {
+	var rows []reportRow
+	for _, s := range recordSeries(rec) {
+		r := s.Result
+		row := reportRow{
+			Name:    s.Name,
+			Rate:    rateSummary(*r),
+			Details: strings.TrimPrefix(strings.TrimSuffix(details(*r, false), ")"), " ("),
+		}
+		if len(r.LoadedLatency) > 0 {
+			row.Latency = latencySummary(r.LoadedLatency)
+			row.Bufferbloat = r.Bufferbloat
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// summaryRow is a row of the summary table of repeated trials.
+type summaryRow struct {
+	Name, Min, Median, Mean, Max, StdDev string
+}
+
+// summaryRows summarizes each metric measured over repeated trials.
+func summaryRows(rec record) []summaryRow {
+	var rows []summaryRow
+	for _, m := range trialMetrics {
+		if s, ok := rec.Summary[m.key]; ok {
+			rows = append(rows, summaryRow{m.label, m.format(s.Min),
+				m.format(s.Median), m.format(s.Mean), m.format(s.Max),
+				m.format(s.StdDev)})
+		}
+	}
+	return rows
+}
+
+// renderReport writes a self-contained HTML report of the record.
+func renderReport(w io.Writer, rec record) error {
+	idle := ""
+	if len(rec.Latency) > 0 {
+		idle = latencySummary(rec.Latency)
+	}
+	return reportTemplate.Execute(w, map[string]interface{}{
+		"Record":     rec,
+		"Time":       rec.Time.Local().Format("2006-01-02 15:04:05 MST"),
+		"Idle":       idle,
+		"Estimates":  estimateRows(rec),
+		"Summary":    summaryRows(rec),
+		"Throughput": template.HTML(throughputChart(rec)),
+		"Latency":    template.HTML(latencyChart(rec)),
+	})
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Speed test report, {{.Time}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em auto; max-width: 760px; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { text-align: left; padding: 0.3em 1em 0.3em 0; border-bottom: 1px solid #ddd; }
+svg { max-width: 100%; height: auto; }
+</style>
+</head>
+<body>
+<h1>Speed test report</h1>
+<p>{{.Time}}</p>
+
+<h2>Client</h2>
+<table>
+{{with .Record.ISP}}<tr><th>ISP</th><td>{{.}}</td></tr>{{end}}
+{{with .Record.IP}}<tr><th>IP address</th><td>{{.}}</td></tr>{{end}}
+{{with .Record.Proxy}}<tr><th>Proxy</th><td>{{.}}</td></tr>{{end}}
+<tr><th>Location</th><td>{{with .Record.Location.Name}}{{$.Record.Location}}, {{end}}{{.Record.Location.Lat}}, {{.Record.Location.Lon}}</td></tr>
+</table>
+
+<h2>Server{{if gt (len .Record.Servers) 1}}s{{end}}</h2>
+<table>
+<tr><th>ID</th><th>Sponsor</th><th>Location</th><th>Distance</th></tr>
+{{range .Record.Servers}}<tr><td>{{.ID}}</td><td>{{.Sponsor}}</td><td>{{.Name}}{{with .Country}}, {{.}}{{end}}</td><td>{{printf "%.0f" .Distance}} km</td></tr>
+{{end}}
+</table>
+
+{{with .Summary}}<h2>Summary of {{len $.Record.Trials}} trials</h2>
+<table>
+<tr><th></th><th>Min</th><th>Median</th><th>Mean</th><th>Max</th><th>Std dev</th></tr>
+{{range .}}<tr><th>{{.Name}}</th><td>{{.Min}}</td><td>{{.Median}}</td><td>{{.Mean}}</td><td>{{.Max}}</td><td>{{.StdDev}}</td></tr>
+{{end}}
+</table>
+
+{{end}}<h2>Results{{if .Summary}} of the last trial{{end}}</h2>
+<table>
+{{with .Idle}}<tr><th>Idle latency</th><td colspan="4">{{.}}</td></tr>{{end}}
+<tr><th>Test</th><th>Rate</th><th>Loaded latency</th><th>Bufferbloat</th><th>Notes</th></tr>
+{{range .Estimates}}<tr><td>{{.Name}}</td><td>{{.Rate}}</td><td>{{.Latency}}</td><td>{{.Bufferbloat}}</td><td>{{.Details}}</td></tr>
+{{end}}
+</table>
+
+{{if .Estimates}}<h2>Throughput</h2>
+{{.Throughput}}{{end}}
+{{with .Latency}}<h2>Latency distribution</h2>
+{{.}}{{end}}
+</body>
+</html>
+`))
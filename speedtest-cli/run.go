@@ -0,0 +1,599 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2014 David Johnston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/johnsto/speedtest"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	cmdListServers    string
+	testUpload        bool
+	testDownload      bool
+	testBidi          bool
+	testLatency       bool
+	sampleServers     string
+	multiServer       int
+	samplePeriod      time.Duration
+	sampleThreads     int
+	sampleMaxThreads  int
+	adaptiveThreads   bool
+	converge          bool
+	convergeWindow    time.Duration
+	convergeLimit     float64
+	minPeriod         time.Duration
+	warmUp            time.Duration
+	autoWarmUp        bool
+	maxBytes          byteSize
+	compareIPVersions bool
+	jsonOutput        bool
+	saveHistory       bool
+	useTUI            bool
+	repeat            int
+	pause             time.Duration
+)
+
+// display shows live progress, if -tui is given and stdout is a terminal.
+var display *tui
+
+// runFlags registers the flags of the run command.
+func runFlags(fs *flag.FlagSet) {
+	fs.StringVar(&cmdListServers, "list-servers", "",
+		"List servers (id|distance|nearest|farthest); see also 'servers'")
+
+	fs.BoolVar(&testUpload, "test-upload", true, "Test upload speed")
+	fs.BoolVar(&testDownload, "test-download", true, "Test download speed")
+	fs.BoolVar(&testBidi, "test-bidirectional", false,
+		"Test simultaneous download and upload speed")
+	fs.BoolVar(&testLatency, "test-latency", true,
+		"Test idle and loaded latency")
+
+	serverListFlags(fs)
+	fs.IntVar(&sampleServer, "server", findNearest,
+		"Server id to test (-1: use nearest, -2: use farthest)")
+	fs.StringVar(&sampleServers, "servers", "",
+		"Comma-separated server ids to test simultaneously")
+	fs.IntVar(&multiServer, "multi-server", 1,
+		"Number of nearest servers to test simultaneously")
+
+	connectionFlags(fs)
+	fs.BoolVar(&compareIPVersions, "compare-ip-versions", false,
+		"Run tests over IPv4 and IPv6 and compare the results")
+
+	fs.DurationVar(&samplePeriod, "period", time.Duration(10*time.Second),
+		"Sampling period")
+	fs.IntVar(&sampleThreads, "threads", 4,
+		"Initial number of benchmark threads")
+	fs.IntVar(&sampleMaxThreads, "max-threads", 16,
+		"Maximum number of benchmark threads")
+	fs.BoolVar(&adaptiveThreads, "adaptive-threads", false,
+		"Add threads only while they improve throughput")
+
+	fs.BoolVar(&converge, "converge", false,
+		"End each test early once the measured rate is stable")
+	fs.DurationVar(&convergeWindow, "converge-window",
+		speedtest.DefaultConvergence.Window,
+		"Period over which the rate must be stable")
+	fs.Float64Var(&convergeLimit, "converge-threshold",
+		speedtest.DefaultConvergence.Threshold,
+		"Maximum coefficient of variation of a stable rate")
+	fs.DurationVar(&minPeriod, "min-period",
+		speedtest.DefaultConvergence.MinDuration,
+		"Minimum sampling period when ending early")
+
+	fs.DurationVar(&warmUp, "warm-up", 0,
+		"Initial period excluded from the estimated rate")
+	fs.BoolVar(&autoWarmUp, "auto-warm-up", false,
+		"Exclude the initial ramp from the estimated rate")
+
+	fs.Var(&maxBytes, "max-bytes",
+		"Maximum data to transfer in each test (e.g. 50MB)")
+
+	fs.IntVar(&repeat, "repeat", 1,
+		"Number of times to run the tests, summarizing the results")
+	fs.DurationVar(&pause, "pause", 0, "Time to pause between repeated runs")
+
+	fs.BoolVar(&useTUI, "tui", false,
+		"Show a live graph of throughput, threads and latency")
+	fs.BoolVar(&jsonOutput, "json", false,
+		"Write the results to stdout as JSON, and progress to stderr")
+	unitsFlag(fs)
+	historyFileFlag(fs)
+	fs.BoolVar(&saveHistory, "history", false,
+		"Record the results in the history file")
+}
+
+// runCommand tests the selected servers.
+func runCommand(fs *flag.FlagSet) {
+	if jsonOutput {
+		out = os.Stderr
+	}
+	s := connect()
+
+	// List servers
+	if cmdListServers != "" {
+		listServers(s.Filtered, cmdListServers, 0)
+		return
+	}
+
+	servers, err := selectServers(s)
+	if err != nil {
+		fmt.Fprintf(out, "Couldn't select server: %v. "+
+			"Re-run with the servers command for a list.\n", err)
+		os.Exit(1)
+	}
+
+	for _, server := range servers {
+		fmt.Fprintf(out, "Using server %d. %v, %v, %v (%dkm)\n",
+			server.ID, server.Sponsor, server.Name, server.Country,
+			int(server.Distance))
+	}
+
+	if compareIPVersions {
+		compare(servers)
+		return
+	}
+
+	if useTUI {
+		f, _ := out.(*os.File)
+		if display = newTUI(f, samplePeriod); display == nil {
+			fmt.Fprintln(os.Stderr, "warning: -tui requires a terminal")
+		}
+	}
+
+	rec := record{
+		Time:     time.Now(),
+		ISP:      s.Config.Client.IspName,
+		IP:       s.Config.Client.IPAddress,
+		Location: s.Location,
+		Servers:  servers,
+	}
+	if s.Proxy != nil {
+		rec.Proxy = s.Proxy.Redacted()
+	}
+	if repeat > 1 {
+		for i := 0; i < repeat; i++ {
+			if i > 0 && pause > 0 {
+				fmt.Fprintf(out, "Pausing for %v...\n", pause)
+				time.Sleep(pause)
+			}
+			fmt.Fprintf(out, "\nTrial %d of %d\n", i+1, repeat)
+			rec.Trials = append(rec.Trials, runTests(s.Client, servers))
+		}
+		rec.Summary = summarize(rec.Trials)
+		fmt.Fprintln(out)
+		printSummary(rec.Summary)
+	} else {
+		rec.results = runTests(s.Client, servers)
+	}
+
+	if saveHistory {
+		if err := appendHistory(rec); err != nil {
+			fmt.Fprintf(out, "warning: couldn't save history: %v\n", err)
+		}
+	}
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(rec); err != nil {
+			fmt.Fprintf(out, "error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// results holds the outcome of each test.
+type results struct {
+	Latency      speedtest.Latencies        `json:"latency,omitempty"`
+	Download     *speedtest.BenchmarkResult `json:"download,omitempty"`
+	Upload       *speedtest.BenchmarkResult `json:"upload,omitempty"`
+	BidiDownload *speedtest.BenchmarkResult `json:"bidiDownload,omitempty"`
+	BidiUpload   *speedtest.BenchmarkResult `json:"bidiUpload,omitempty"`
+}
+
+// runTests runs each of the selected tests against the given servers,
+// printing progress and results as it goes.
+func runTests(client http.Client, servers speedtest.Servers) results {
+	var r results
+
+	opts := speedtest.BenchmarkOptions{
+		Threads:    sampleThreads,
+		MaxThreads: sampleMaxThreads,
+		Duration:   samplePeriod,
+		WarmUp:     warmUp,
+		AutoWarmUp: autoWarmUp,
+		MaxBytes:   maxBytes.bytes,
+	}
+	if adaptiveThreads {
+		opts.Scaling = speedtest.ScaleAdaptive
+	}
+	if converge {
+		opts.Convergence = &speedtest.Convergence{
+			Window:      convergeWindow,
+			Threshold:   convergeLimit,
+			MinDuration: minPeriod,
+		}
+	}
+
+	var downloads, uploads []speedtest.Benchmark
+	for _, server := range servers {
+		downloads = append(downloads, speedtest.NewDownloadBenchmark(client, server))
+		uploads = append(uploads, speedtest.NewUploadBenchmark(client, server))
+	}
+	download, upload := downloads[0], uploads[0]
+	if len(servers) > 1 {
+		download = speedtest.NewMultiBenchmark(downloads...)
+		upload = speedtest.NewMultiBenchmark(uploads...)
+	}
+
+	if testLatency {
+		pinger := speedtest.NewPinger(client, servers[0])
+		fmt.Fprint(out, "Testing idle latency... ")
+		idle, err := speedtest.MeasureLatency(pinger, speedtest.IdlePings)
+		if err != nil {
+			fmt.Fprintf(out, "error: %v\n", err)
+		} else {
+			fmt.Fprintln(out, latencySummary(idle))
+			opts.Pinger = pinger
+			opts.IdleLatency = idle
+			r.Latency = idle
+			if display != nil {
+				display.SetIdleLatency(idle)
+			}
+		}
+	}
+
+	var downloadRate, uploadRate int
+
+	if testDownload {
+		result := measure("Download", "Testing download speed... ", download, opts)
+		r.Download = &result
+		downloadRate = result.Rate
+		fmt.Fprintln(out, rateSummary(result)+details(result, verbose))
+		printContributions(servers, result)
+		printLoadedLatency(result)
+		printTimings(result.Timings)
+	}
+
+	if testUpload {
+		result := measure("Upload", "Testing upload speed... ", upload, opts)
+		r.Upload = &result
+		uploadRate = result.Rate
+		fmt.Fprintln(out, rateSummary(result)+details(result, verbose))
+		printContributions(servers, result)
+		printLoadedLatency(result)
+		printTimings(result.Timings)
+	}
+
+	if testBidi {
+		fmt.Fprintf(out, "Testing simultaneous download and upload speed...\n")
+		downOpts, upOpts := opts, opts
+		if display != nil {
+			downOpts.Progress = display.Progress("Download")
+			upOpts.Progress = display.Progress("Upload")
+		}
+		down, up := speedtest.MeasureBidirectionalWith(download, upload, downOpts, upOpts)
+		if display != nil {
+			display.Clear()
+		}
+		r.BidiDownload, r.BidiUpload = &down, &up
+		fmt.Fprintf(out, "  Download: %v%v\n", rateSummary(down),
+			isolated(downloadRate))
+		fmt.Fprintf(out, "  Upload: %v%v\n", rateSummary(up),
+			isolated(uploadRate))
+		printLoadedLatency(down)
+	}
+
+	return r
+}
+
+// trialMetric is a metric summarized over repeated trials.
+type trialMetric struct {
+	key     string
+	label   string
+	latency bool
+	value   func(results) (float64, bool)
+}
+
+// trialMetrics lists the metrics summarized over repeated trials.
+var trialMetrics = []trialMetric{
+	{"latency", "Idle latency", true, func(r results) (float64, bool) {
+		return float64(r.Latency.Median()), len(r.Latency) > 0
+	}},
+	{"download", "Download", false, rateOf(func(r results) *speedtest.BenchmarkResult { return r.Download })},
+	{"downloadLatency", "Download latency", true, loadedOf(func(r results) *speedtest.BenchmarkResult { return r.Download })},
+	{"upload", "Upload", false, rateOf(func(r results) *speedtest.BenchmarkResult { return r.Upload })},
+	{"uploadLatency", "Upload latency", true, loadedOf(func(r results) *speedtest.BenchmarkResult { return r.Upload })},
+	{"bidiDownload", "Bidi download", false, rateOf(func(r results) *speedtest.BenchmarkResult { return r.BidiDownload })},
+	{"bidiUpload", "Bidi upload", false, rateOf(func(r results) *speedtest.BenchmarkResult { return r.BidiUpload })},
+}
+
+// findMetric returns the trial metric with the given key, if there is one.
+func findMetric(key string) (trialMetric, bool) {
+	for _, m := range trialMetrics {
+		if m.key == key {
+			return m, true
+		}
+	}
+	return trialMetric{}, false
+}
+
+// rateOf returns the estimated rate of the chosen result, in bytes/sec.
+func rateOf(result func(results) *speedtest.BenchmarkResult) func(results) (float64, bool) {
+	return func(r results) (float64, bool) {
+		if b := result(r); b != nil {
+			return float64(b.Rate), true
+		}
+		return 0, false
+	}
+}
+
+// loadedOf returns the median loaded latency of the chosen result.
+func loadedOf(result func(results) *speedtest.BenchmarkResult) func(results) (float64, bool) {
+	return func(r results) (float64, bool) {
+		if b := result(r); b != nil && len(b.LoadedLatency) > 0 {
+			return float64(b.LoadedLatency.Median()), true
+		}
+		return 0, false
+	}
+}
+
+// summarize summarizes each metric measured in the given trials.
+func summarize(trials []results) map[string]speedtest.Summary {
+	summary := map[string]speedtest.Summary{}
+	for _, m := range trialMetrics {
+		var values []float64
+		for _, r := range trials {
+			if v, ok := m.value(r); ok {
+				values = append(values, v)
+			}
+		}
+		if len(values) > 0 {
+			summary[m.key] = speedtest.Summarize(values)
+		}
+	}
+	return summary
+}
+
+// printSummary prints the summary of each metric.
+func printSummary(summary map[string]speedtest.Summary) {
+	fmt.Fprintf(out, "%-17v %14v %14v %14v %14v %14v\n",
+		"", "Min", "Median", "Mean", "Max", "Std dev")
+	for _, m := range trialMetrics {
+		s, ok := summary[m.key]
+		if !ok {
+			continue
+		}
+		f := m.format
+		fmt.Fprintf(out, "%-17v %14v %14v %14v %14v %14v\n", m.label,
+			f(s.Min), f(s.Median), f(s.Mean), f(s.Max), f(s.StdDev))
+	}
+}
+
+// format formats a value of the metric.
+func (m trialMetric) format(v float64) string {
+	if m.latency {
+		return time.Duration(v).Round(time.Millisecond / 10).String()
+	}
+	return niceRate(int(v))
+}
+
+// measure runs a benchmark, printing the given prefix before its result.
+// Progress is shown in the meantime if -tui is in use.
+func measure(label, prefix string, b speedtest.Benchmark, opts speedtest.BenchmarkOptions) speedtest.BenchmarkResult {
+	if display == nil {
+		fmt.Fprint(out, prefix)
+		return speedtest.Measure(b, opts)
+	}
+	opts.Progress = display.Progress(label)
+	result := speedtest.Measure(b, opts)
+	display.Clear()
+	fmt.Fprint(out, prefix)
+	return result
+}
+
+// compare runs the selected tests over IPv4 and IPv6 in turn, and prints
+// their results side by side.
+func compare(servers speedtest.Servers) {
+	families := []struct {
+		name    string
+		network string
+	}{
+		{"IPv4", speedtest.IPv4},
+		{"IPv6", speedtest.IPv6},
+	}
+
+	var all []results
+	for _, family := range families {
+		fmt.Fprintf(out, "\nTesting over %v...\n", family.name)
+		client, err := newClient(family.network)
+		if err == nil {
+			_, err = speedtest.NewPinger(client, servers[0]).Ping()
+		}
+		if err != nil {
+			fmt.Fprintf(out, "  unavailable: %v\n", err)
+			all = append(all, results{})
+			continue
+		}
+		all = append(all, runTests(client, servers))
+	}
+
+	printComparison(families[0].name, families[1].name, all[0], all[1])
+}
+
+// printComparison prints a table comparing two sets of results. Rows for
+// bidirectional tests are shown only if either set includes them.
+func printComparison(nameA, nameB string, a, b results) {
+	fmt.Fprintf(out, "\n%-14v %22v %22v\n", "", nameA, nameB)
+	row := func(name string, value func(results) string) {
+		fmt.Fprintf(out, "%-14v %22v %22v\n", name, value(a), value(b))
+	}
+	row("Latency", func(r results) string {
+		if len(r.Latency) == 0 {
+			return "-"
+		}
+		return r.Latency.Median().Round(time.Millisecond / 10).String()
+	})
+	rate := func(b *speedtest.BenchmarkResult) string {
+		if b == nil {
+			return "-"
+		}
+		return rateSummary(*b)
+	}
+	row("Download", func(r results) string { return rate(r.Download) })
+	row("Upload", func(r results) string { return rate(r.Upload) })
+	if a.BidiDownload != nil || b.BidiDownload != nil {
+		row("Bidi download", func(r results) string { return rate(r.BidiDownload) })
+		row("Bidi upload", func(r results) string { return rate(r.BidiUpload) })
+	}
+}
+
+// printLoadedLatency prints the latency measured during a benchmark, if any.
+func printLoadedLatency(r speedtest.BenchmarkResult) {
+	if len(r.LoadedLatency) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "  Loaded latency: %v (bufferbloat: %v)\n",
+		latencySummary(r.LoadedLatency), r.Bufferbloat)
+}
+
+// selectServers chooses the servers to test according to the command line
+// flags.
+func selectServers(s session) (speedtest.Servers, error) {
+	if sampleServers != "" {
+		var selected speedtest.Servers
+		for _, field := range strings.Split(sampleServers, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil {
+				return nil, fmt.Errorf("invalid server id %q", field)
+			}
+			server, ok := findServer(s.Settings.Servers, id)
+			if !ok {
+				return nil, fmt.Errorf("could not find server %d", id)
+			}
+			selected = append(selected, server)
+		}
+		return selected, nil
+	}
+
+	if multiServer > 1 {
+		if len(s.Filtered) == 0 {
+			return nil, fmt.Errorf("no servers match filters")
+		}
+		s.Filtered.SortByDistance()
+		if multiServer > len(s.Filtered) {
+			multiServer = len(s.Filtered)
+		}
+		return s.Filtered[:multiServer], nil
+	}
+
+	server, err := selectServer(s)
+	if err != nil {
+		return nil, err
+	}
+	return speedtest.Servers{server}, nil
+}
+
+// printContributions prints each server's share of a multi-server result.
+func printContributions(servers speedtest.Servers, r speedtest.BenchmarkResult) {
+	var total int64
+	for _, n := range r.Contributions {
+		total += n
+	}
+	if total == 0 {
+		return
+	}
+	for i, n := range r.Contributions {
+		share := float64(n) / float64(total)
+		fmt.Fprintf(out, "  %5d. %v: %.0f%% (%v)\n", servers[i].ID, servers[i].Sponsor,
+			100*share, niceRate(int(share*float64(r.Rate))))
+	}
+}
+
+// details formats any notable details of how a benchmark ran, as recorded in
+// its result. If verbose, the confidence interval is included, and the
+// protocol even if unencrypted.
+func details(r speedtest.BenchmarkResult, verbose bool) string {
+	var notes []string
+	if r.Protocol != "" && (r.TLSVersion != "" || verbose) {
+		notes = append(notes, strings.TrimSpace(r.Protocol+" "+r.TLSVersion))
+	}
+	if verbose && r.Rate > 0 {
+		notes = append(notes, fmt.Sprintf("%.0f%% confidence %v-%v, CV %.1f%%",
+			100*speedtest.Confidence, niceRate(r.RateLow),
+			niceRate(r.RateHigh), 100*r.Variation))
+	}
+	if r.Scaling == speedtest.ScaleAdaptive {
+		notes = append(notes, fmt.Sprintf("%d threads", r.Threads))
+	}
+	if r.Convergence != nil {
+		if r.Converged {
+			notes = append(notes, fmt.Sprintf("converged after %v",
+				r.Duration.Round(time.Second/10)))
+		} else {
+			notes = append(notes, "did not converge")
+		}
+	}
+	if r.CapReached {
+		notes = append(notes, fmt.Sprintf("data cap of %v reached after %v",
+			niceBytes(r.MaxBytes), r.Duration.Round(time.Second/10)))
+	}
+	if r.WarmUp > 0 {
+		notes = append(notes, fmt.Sprintf(
+			"excluded %v warm-up, mean including warm-up %v",
+			r.WarmUp, niceRate(r.MeanRate)))
+	}
+	if len(notes) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(notes, ", ") + ")"
+}
+
+// rateSummary formats the estimated rate of a result with the half-width of
+// its confidence interval as a margin of error.
+func rateSummary(r speedtest.BenchmarkResult) string {
+	margin := (r.RateHigh - r.RateLow) / 2
+	if margin <= 0 {
+		return niceRate(r.Rate)
+	}
+	return speedtest.Rate(r.Rate).FormatMargin(speedtest.Rate(margin), units.format)
+}
+
+// isolated formats a rate measured without simultaneous load for display
+// alongside a rate measured under load.
+func isolated(rate int) string {
+	if rate == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (isolated: %v)", niceRate(rate))
+}
@@ -0,0 +1,72 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2014 David Johnston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/johnsto/speedtest"
+	"net/http"
+	"os"
+)
+
+var (
+	serveAddr    string
+	serveTLSCert string
+	serveTLSKey  string
+)
+
+// serveFlags registers the flags of the serve command.
+func serveFlags(fs *flag.FlagSet) {
+	fs.StringVar(&serveAddr, "listen", ":8080", "Address to listen on")
+	fs.StringVar(&serveTLSCert, "tls-cert", "",
+		"Certificate file for serving HTTPS")
+	fs.StringVar(&serveTLSKey, "tls-key", "",
+		"Private key file for serving HTTPS")
+}
+
+// serveCommand runs a test server that other clients can test against.
+func serveCommand(fs *flag.FlagSet) {
+	if (serveTLSCert == "") != (serveTLSKey == "") {
+		fmt.Fprintln(out, "-tls-cert and -tls-key must be given together")
+		os.Exit(2)
+	}
+	scheme := "http"
+	if serveTLSCert != "" {
+		scheme = "https"
+	}
+	fmt.Fprintf(out, "Serving tests at %v://%v/speedtest/upload.php\n",
+		scheme, serveAddr)
+
+	server := &http.Server{Addr: serveAddr, Handler: speedtest.NewHandler()}
+	var err error
+	if serveTLSCert != "" {
+		err = server.ListenAndServeTLS(serveTLSCert, serveTLSKey)
+	} else {
+		err = server.ListenAndServe()
+	}
+	fmt.Fprintf(out, "error: %v\n", err)
+	os.Exit(1)
+}
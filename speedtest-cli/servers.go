@@ -0,0 +1,96 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2014 David Johnston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/johnsto/speedtest"
+	"os"
+)
+
+var (
+	serversSort  string
+	serversLimit int
+)
+
+// serversFlags registers the flags of the servers command.
+func serversFlags(fs *flag.FlagSet) {
+	fs.StringVar(&serversSort, "sort", "distance",
+		"Order servers by (id|distance|nearest|farthest)")
+	fs.IntVar(&serversLimit, "limit", 0,
+		"Maximum number of servers to list (nearest and farthest: 10)")
+	fs.BoolVar(&jsonOutput, "json", false,
+		"Write the servers to stdout as JSON, and progress to stderr")
+	serverListFlags(fs)
+	connectionFlags(fs)
+}
+
+// serversCommand lists the servers matching the filters.
+func serversCommand(fs *flag.FlagSet) {
+	if jsonOutput {
+		out = os.Stderr
+	}
+	s := connect()
+	listServers(s.Filtered, serversSort, serversLimit)
+}
+
+// listServers prints the servers in the given order, limited to the given
+// number of servers if positive.
+func listServers(servers speedtest.Servers, order string, limit int) {
+	switch order {
+	case "id":
+		servers.SortByID()
+	case "distance":
+		servers.SortByDistance()
+	case "nearest", "farthest":
+		servers.SortByDistance()
+		if limit <= 0 {
+			limit = 10
+		}
+	default:
+		fmt.Fprintf(out, "Unknown order %q\n", order)
+		os.Exit(2)
+	}
+	if limit > 0 && len(servers) > limit {
+		if order == "farthest" {
+			servers = servers[len(servers)-limit:]
+		} else {
+			servers = servers[:limit]
+		}
+	}
+
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(servers)
+		return
+	}
+	for _, server := range servers {
+		fmt.Fprintf(out, "%5d. [%v] (%dkm) %v\n",
+			server.ID, server.CountryCode, int(server.Distance), server.Name)
+	}
+}
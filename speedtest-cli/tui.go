@@ -0,0 +1,280 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2014 David Johnston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package main
+
+import (
+	"fmt"
+	"github.com/johnsto/speedtest"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// sparkWidth is the maximum number of samples shown in a sparkline.
+	sparkWidth = 60
+	// gaugeWidth is the width of the latency gauge.
+	gaugeWidth = 20
+	// gaugeMax is the latency at which the gauge is full.
+	gaugeMax = 250 * time.Millisecond
+)
+
+// sparks are the characters of a sparkline, from lowest to highest.
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// ANSI escape sequences
+const (
+	ansiUp         = "\x1b[%dA"
+	ansiClearLine  = "\x1b[2K"
+	ansiHideCursor = "\x1b[?25l"
+	ansiShowCursor = "\x1b[?25h"
+	ansiBold       = "\x1b[1m"
+	ansiReset      = "\x1b[0m"
+	ansiGreen      = "\x1b[32m"
+	ansiYellow     = "\x1b[33m"
+	ansiRed        = "\x1b[31m"
+)
+
+// tui draws live progress of benchmarks, redrawing in place beneath the
+// lines already printed.
+type tui struct {
+	mu       sync.Mutex
+	out      *os.File
+	width    int
+	duration time.Duration
+	idle     time.Duration
+	panels   []*panel
+	// drawn is the number of lines last drawn.
+	drawn int
+}
+
+// panel holds the latest progress of one benchmark.
+type panel struct {
+	label    string
+	progress speedtest.Progress
+}
+
+// newTUI creates a display writing to the given file, or returns nil if it
+// isn't a terminal.
+func newTUI(f *os.File, duration time.Duration) *tui {
+	if !isTerminal(f) {
+		return nil
+	}
+	width := 80
+	if cols, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && cols > 0 {
+		width = cols
+	}
+	t := &tui{out: f, width: width, duration: duration}
+
+	// Restore the cursor if interrupted mid-drawing
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		fmt.Fprintln(f, ansiShowCursor)
+		os.Exit(130)
+	}()
+	return t
+}
+
+// isTerminal returns true if the file is a terminal that understands ANSI
+// escape sequences.
+func isTerminal(f *os.File) bool {
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// SetIdleLatency sets the idle latency against which loaded latency is
+// compared.
+func (t *tui) SetIdleLatency(l speedtest.Latencies) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.idle = l.Median()
+}
+
+// Progress adds a panel with the given label, returning a function that
+// updates it for use as BenchmarkOptions.Progress.
+func (t *tui) Progress(label string) func(speedtest.Progress) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p := &panel{label: label}
+	t.panels = append(t.panels, p)
+	return func(progress speedtest.Progress) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		p.progress = progress
+		t.draw()
+	}
+}
+
+// Clear removes all panels from the screen.
+func (t *tui) Clear() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.erase()
+	t.panels = nil
+	fmt.Fprint(t.out, ansiShowCursor)
+}
+
+// erase moves the cursor back over the lines last drawn, clearing them.
+func (t *tui) erase() {
+	if t.drawn > 0 {
+		fmt.Fprintf(t.out, ansiUp, t.drawn)
+	}
+	for i := 0; i < t.drawn; i++ {
+		fmt.Fprint(t.out, ansiClearLine+"\n")
+	}
+	if t.drawn > 0 {
+		fmt.Fprintf(t.out, ansiUp, t.drawn)
+	}
+	t.drawn = 0
+}
+
+// draw redraws every panel in place.
+func (t *tui) draw() {
+	var lines []string
+	for _, p := range t.panels {
+		lines = append(lines, t.render(p)...)
+	}
+	var b strings.Builder
+	b.WriteString(ansiHideCursor)
+	if t.drawn > 0 {
+		fmt.Fprintf(&b, ansiUp, t.drawn)
+	}
+	for _, line := range lines {
+		b.WriteString(ansiClearLine + line + "\n")
+	}
+	// Clear any lines left over from a taller drawing
+	for i := len(lines); i < t.drawn; i++ {
+		b.WriteString(ansiClearLine + "\n")
+	}
+	if extra := t.drawn - len(lines); extra > 0 {
+		fmt.Fprintf(&b, ansiUp, extra)
+	}
+	t.out.WriteString(b.String())
+	t.drawn = len(lines)
+}
+
+// render formats the lines of a panel.
+func (t *tui) render(p *panel) []string {
+	progress := p.progress
+	samples := progress.Samples
+	width := sparkWidth
+	if w := t.width - 24; w < width {
+		width = w
+	}
+	if width < 10 {
+		width = 10
+	}
+	if len(samples) > width {
+		samples = samples[len(samples)-width:]
+	}
+
+	return []string{
+		fmt.Sprintf("%v%-9v%v %-*v %v", ansiBold, p.label, ansiReset,
+			width, sparkline(samples), niceRate(currentRate(progress))),
+		fmt.Sprintf("  %v / %v, %d threads, %v transferred",
+			progress.Elapsed.Round(time.Second/10), t.duration, progress.Threads,
+			niceBytes(progress.Bytes)),
+		"  Latency " + t.gauge(progress.Latency),
+	}
+}
+
+// currentRate returns the rate over the most recent second of samples, in
+// bytes/sec.
+func currentRate(p speedtest.Progress) int {
+	if p.Resolution <= 0 || len(p.Samples) == 0 {
+		return 0
+	}
+	n := int(time.Second / p.Resolution)
+	samples := p.Samples
+	if len(samples) > n {
+		samples = samples[len(samples)-n:]
+	}
+	sum := 0
+	for _, s := range samples {
+		sum += s
+	}
+	return int(float64(sum) / (time.Duration(len(samples)) * p.Resolution).Seconds())
+}
+
+// sparkline draws the given values as a line of bars scaled to the largest.
+func sparkline(values []int) string {
+	max := 0
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	line := make([]rune, len(values))
+	for i, v := range values {
+		level := 0
+		if max > 0 {
+			level = v * (len(sparks) - 1) / max
+		}
+		line[i] = sparks[level]
+	}
+	return string(line)
+}
+
+// gauge draws a bar showing latency, coloured by its increase over idle.
+func (t *tui) gauge(latency time.Duration) string {
+	if latency <= 0 {
+		return "[" + strings.Repeat(" ", gaugeWidth) + "] -"
+	}
+	filled := int(latency * gaugeWidth / gaugeMax)
+	if filled > gaugeWidth {
+		filled = gaugeWidth
+	} else if filled < 1 {
+		filled = 1
+	}
+	color := ansiGreen
+	switch increase := latency - t.idle; {
+	case increase >= 200*time.Millisecond:
+		color = ansiRed
+	case increase >= 60*time.Millisecond:
+		color = ansiYellow
+	}
+	return fmt.Sprintf("[%v%v%v%v] %v", color, strings.Repeat("█", filled),
+		ansiReset, strings.Repeat(" ", gaugeWidth-filled),
+		latency.Round(time.Millisecond/10))
+}
+
+// niceBytes formats a quantity of data in SI units.
+func niceBytes(n int64) string {
+	units := []string{"B", "kB", "MB", "GB", "TB"}
+	value := float64(n)
+	i := 0
+	for ; value >= 1000 && i < len(units)-1; i++ {
+		value /= 1000
+	}
+	return fmt.Sprintf("%.1f%v", value, units[i])
+}
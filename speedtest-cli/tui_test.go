@@ -0,0 +1,69 @@
+package main
+
+import (
+	"github.com/johnsto/speedtest"
+	. "github.com/smartystreets/goconvey/convey"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Sparkline(t *testing.T) {
+	Convey("Sparklines should be scaled to the largest value", t, func() {
+		for _, c := range []struct {
+			values []int
+			line   string
+		}{
+			{nil, ""},
+			{[]int{5}, "█"},
+			{[]int{5, 5, 5}, "███"},
+			{[]int{0, 0, 0}, "▁▁▁"},
+			{[]int{0, 7, 14}, "▁▄█"},
+		} {
+			So(sparkline(c.values), ShouldEqual, c.line)
+		}
+	})
+}
+
+func Test_CurrentRate(t *testing.T) {
+	Convey("The current rate should be over the most recent second", t, func() {
+		res := 100 * time.Millisecond
+		flat := make([]int, 20)
+		for i := range flat {
+			flat[i] = 100
+		}
+		for _, c := range []struct {
+			progress speedtest.Progress
+			rate     int
+		}{
+			{speedtest.Progress{Resolution: res}, 0},
+			{speedtest.Progress{Samples: []int{100}}, 0},
+			{speedtest.Progress{Samples: []int{100}, Resolution: res}, 1000},
+			{speedtest.Progress{Samples: flat, Resolution: res}, 1000},
+			{speedtest.Progress{Samples: append(flat, 0, 0, 0, 0, 0),
+				Resolution: res}, 500},
+		} {
+			So(currentRate(c.progress), ShouldEqual, c.rate)
+		}
+	})
+}
+
+func Test_Gauge(t *testing.T) {
+	Convey("The latency gauge should be scaled to its maximum", t, func() {
+		ui := tui{}
+		for _, c := range []struct {
+			latency time.Duration
+			filled  int
+		}{
+			{0, 0},
+			{time.Microsecond, 1},
+			{gaugeMax / 2, gaugeWidth / 2},
+			{gaugeMax, gaugeWidth},
+			{2 * gaugeMax, gaugeWidth},
+		} {
+			g := ui.gauge(c.latency)
+			So(strings.Count(g, "█"), ShouldEqual, c.filled)
+			So(strings.Count(g, " "), ShouldEqual, gaugeWidth-c.filled+1)
+		}
+	})
+}
@@ -31,16 +31,16 @@ import (
 
 // Server encompasses a server definition returned by the speedtest.net API
 type Server struct {
-	ID          int     `xml:"id,attr"`
-	URL         string  `xml:"url,attr"`
-	Lat         float64 `xml:"lat,attr"`
-	Lon         float64 `xml:"lon,attr"`
-	Name        string  `xml:"name,attr"`
-	Country     string  `xml:"country,attr"`
-	CountryCode string  `xml:"cc,attr"`
-	Sponsor     string  `xml:"sponsor,attr"`
+	ID          int     `xml:"id,attr" json:"id" yaml:"id"`
+	URL         string  `xml:"url,attr" json:"url" yaml:"url"`
+	Lat         float64 `xml:"lat,attr" json:"lat" yaml:"lat"`
+	Lon         float64 `xml:"lon,attr" json:"lon" yaml:"lon"`
+	Name        string  `xml:"name,attr" json:"name" yaml:"name"`
+	Country     string  `xml:"country,attr" json:"country" yaml:"country"`
+	CountryCode string  `xml:"cc,attr" json:"cc" yaml:"cc"`
+	Sponsor     string  `xml:"sponsor,attr" json:"sponsor" yaml:"sponsor"`
 	// Distance is calculated locally from the client configuration
-	Distance float64
+	Distance float64 `json:"distance,omitempty" yaml:"-"`
 }
 
 // Servers represents a sortable list of servers
@@ -63,6 +63,22 @@ func (s byID) Less(i, j int) bool {
 	return s.Servers[i].ID < s.Servers[j].ID
 }
 
+// Merge returns the servers combined with the other servers, which replace
+// any servers with the same ID.
+func (s Servers) Merge(other Servers) Servers {
+	ids := make(map[int]bool, len(other))
+	for _, server := range other {
+		ids[server.ID] = true
+	}
+	merged := make(Servers, 0, len(s)+len(other))
+	for _, server := range s {
+		if !ids[server.ID] {
+			merged = append(merged, server)
+		}
+	}
+	return append(merged, other...)
+}
+
 // SortByID sorts their servers by their numerical ID.
 func (s Servers) SortByID() {
 	sort.Sort(byID{s})
@@ -75,8 +91,8 @@ func (s Servers) SortByDistance() {
 
 // Settings encompasses server settings data provided by the speedtest.net API
 type Settings struct {
-	XMLName xml.Name `xml:"settings"`
-	Servers Servers  `xml:"servers>server"`
+	XMLName xml.Name `xml:"settings" json:"-" yaml:"-"`
+	Servers Servers  `xml:"servers>server" json:"servers" yaml:"servers"`
 }
 
 // Config encompasses configuration data provided by the speedtest.net API
@@ -95,9 +111,14 @@ type Client struct {
 
 // UpdateDistances updates the Servers with the current latitude/longitude
 func (settings Settings) UpdateDistances(lat float64, lon float64) {
+	settings.UpdateDistancesWith(lat, lon, DistanceDegrees)
+}
+
+// UpdateDistancesWith updates the Servers with the current
+// latitude/longitude, using the given distance function.
+func (settings Settings) UpdateDistancesWith(lat float64, lon float64, distance DistanceFunc) {
 	for i, server := range settings.Servers {
-		settings.Servers[i].Distance = Distance(
-			server.Lat*degToRad, server.Lon*degToRad,
-			lat*degToRad, lon*degToRad)
+		settings.Servers[i].Distance = distance(
+			server.Lat, server.Lon, lat, lon)
 	}
 }
@@ -0,0 +1,199 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2014 David Johnston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package speedtest
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// A Traceable benchmark can record the phase timings of its requests.
+type Traceable interface {
+	Benchmark
+	// WithTrace returns a copy of the benchmark that records its requests
+	// to the given recorder.
+	WithTrace(r *TraceRecorder) Benchmark
+}
+
+// PhaseTimings holds the time spent in each phase of a number of HTTP
+// requests. Connection phases are only recorded for requests that did not
+// reuse an existing connection. In JSON, times are integer nanoseconds.
+type PhaseTimings struct {
+	// DNS holds the time taken to resolve the server's address.
+	DNS Latencies `json:"dns,omitempty"`
+	// Connect holds the time taken to establish a TCP connection.
+	Connect Latencies `json:"connect,omitempty"`
+	// TLS holds the time taken to complete a TLS handshake.
+	TLS Latencies `json:"tls,omitempty"`
+	// TTFB holds the time between a request being sent and the first byte
+	// of its response arriving.
+	TTFB Latencies `json:"ttfb,omitempty"`
+	// Transfer holds the time spent sending request bodies and receiving
+	// response bodies.
+	Transfer Latencies `json:"transfer,omitempty"`
+}
+
+// A TraceRecorder records the phase timings of HTTP requests. A nil
+// TraceRecorder records nothing.
+type TraceRecorder struct {
+	mu         sync.Mutex
+	phases     PhaseTimings
+	protocol   string
+	tlsVersion string
+}
+
+// NewTraceRecorder creates an empty TraceRecorder.
+func NewTraceRecorder() *TraceRecorder {
+	return &TraceRecorder{}
+}
+
+// Timings returns the phase timings recorded so far.
+func (r *TraceRecorder) Timings() PhaseTimings {
+	if r == nil {
+		return PhaseTimings{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return PhaseTimings{
+		DNS:      append(Latencies(nil), r.phases.DNS...),
+		Connect:  append(Latencies(nil), r.phases.Connect...),
+		TLS:      append(Latencies(nil), r.phases.TLS...),
+		TTFB:     append(Latencies(nil), r.phases.TTFB...),
+		Transfer: append(Latencies(nil), r.phases.Transfer...),
+	}
+}
+
+// Protocol returns the HTTP version and TLS version (empty if unencrypted)
+// of the connection most recently used by a traced request.
+func (r *TraceRecorder) Protocol() (proto string, tlsVersion string) {
+	if r == nil {
+		return "", ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.protocol, r.tlsVersion
+}
+
+// gotConn records the protocol used by the given connection.
+func (r *TraceRecorder) gotConn(info httptrace.GotConnInfo) {
+	proto, tlsVersion := HTTP1, ""
+	if conn, ok := info.Conn.(*tls.Conn); ok {
+		state := conn.ConnectionState()
+		if state.NegotiatedProtocol == "h2" {
+			proto = HTTP2
+		}
+		tlsVersion = tls.VersionName(state.Version)
+	}
+	r.mu.Lock()
+	r.protocol, r.tlsVersion = proto, tlsVersion
+	r.mu.Unlock()
+}
+
+// Trace instruments the given request, returning the request to send in its
+// place and a function to call once its response body has been consumed.
+func (r *TraceRecorder) Trace(req *http.Request) (*http.Request, func()) {
+	if r == nil {
+		return req, func() {}
+	}
+
+	var mu sync.Mutex
+	var t struct {
+		dnsStart, dnsDone         time.Time
+		connectStart, connectDone time.Time
+		tlsStart, tlsDone         time.Time
+		wroteHeaders, wroteReq    time.Time
+		firstByte                 time.Time
+	}
+	now := func(ts *time.Time) {
+		mu.Lock()
+		*ts = time.Now()
+		mu.Unlock()
+	}
+
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { now(&t.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { now(&t.dnsDone) },
+		ConnectStart: func(network, addr string) {
+			mu.Lock()
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+			mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			if err == nil {
+				now(&t.connectDone)
+			}
+		},
+		GotConn:           r.gotConn,
+		TLSHandshakeStart: func() { now(&t.tlsStart) },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			now(&t.tlsDone)
+		},
+		WroteHeaders: func() { now(&t.wroteHeaders) },
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			now(&t.wroteReq)
+		},
+		GotFirstResponseByte: func() { now(&t.firstByte) },
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	return req, func() {
+		end := time.Now()
+		mu.Lock()
+		defer mu.Unlock()
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		p := &r.phases
+		if !t.dnsDone.IsZero() {
+			p.DNS = append(p.DNS, t.dnsDone.Sub(t.dnsStart))
+		}
+		if !t.connectDone.IsZero() {
+			p.Connect = append(p.Connect, t.connectDone.Sub(t.connectStart))
+		}
+		if !t.tlsDone.IsZero() {
+			p.TLS = append(p.TLS, t.tlsDone.Sub(t.tlsStart))
+		}
+		if !t.firstByte.IsZero() && !t.wroteReq.IsZero() {
+			ttfb := t.firstByte.Sub(t.wroteReq)
+			if ttfb < 0 {
+				// Server responded before the request was fully sent
+				ttfb = 0
+			}
+			p.TTFB = append(p.TTFB, ttfb)
+		}
+		if !t.firstByte.IsZero() {
+			transfer := end.Sub(t.firstByte)
+			if !t.wroteHeaders.IsZero() && t.wroteReq.After(t.wroteHeaders) {
+				transfer += t.wroteReq.Sub(t.wroteHeaders)
+			}
+			p.Transfer = append(p.Transfer, transfer)
+		}
+	}
+}
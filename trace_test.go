@@ -0,0 +1,45 @@
+package speedtest
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_TraceRecorder(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(10 * time.Millisecond)
+			w.Write(make([]byte, 1024))
+		}))
+	defer ts.Close()
+
+	Convey("Requests should record their phase timings", t, func() {
+		rec := NewTraceRecorder()
+		api := NewAPI(http.Client{})
+		api.Trace = rec
+
+		_, err := api.Fetch(ts.URL)
+		So(err, ShouldBeNil)
+		_, err = api.Fetch(ts.URL)
+		So(err, ShouldBeNil)
+
+		timings := rec.Timings()
+		So(len(timings.Connect), ShouldEqual, 1)
+		So(len(timings.TLS), ShouldEqual, 0)
+		So(len(timings.TTFB), ShouldEqual, 2)
+		So(timings.TTFB.Median(), ShouldBeGreaterThanOrEqualTo, 10*time.Millisecond)
+		So(len(timings.Transfer), ShouldEqual, 2)
+	})
+
+	Convey("A nil recorder should record nothing", t, func() {
+		var rec *TraceRecorder
+		req, _ := http.NewRequest("GET", ts.URL, nil)
+		traced, finish := rec.Trace(req)
+		finish()
+		So(traced, ShouldEqual, req)
+		So(len(rec.Timings().TTFB), ShouldEqual, 0)
+	})
+}
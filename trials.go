@@ -0,0 +1,102 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2014 David Johnston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package speedtest
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Summary describes the distribution of a metric over repeated trials.
+type Summary struct {
+	N      int     `json:"n"`
+	Min    float64 `json:"min"`
+	Median float64 `json:"median"`
+	Mean   float64 `json:"mean"`
+	Max    float64 `json:"max"`
+	// StdDev is the sample standard deviation.
+	StdDev float64 `json:"stddev"`
+}
+
+// Summarize calculates summary statistics of the given values.
+func Summarize(values []float64) Summary {
+	s := Summary{N: len(values)}
+	if len(values) == 0 {
+		return s
+	}
+
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	s.Min, s.Max = sorted[0], sorted[len(sorted)-1]
+	if mid := len(sorted) / 2; len(sorted)%2 == 1 {
+		s.Median = sorted[mid]
+	} else {
+		s.Median = (sorted[mid-1] + sorted[mid]) / 2
+	}
+
+	for _, v := range values {
+		s.Mean += v
+	}
+	s.Mean /= float64(len(values))
+	if len(values) > 1 {
+		for _, v := range values {
+			s.StdDev += (v - s.Mean) * (v - s.Mean)
+		}
+		s.StdDev = math.Sqrt(s.StdDev / float64(len(values)-1))
+	}
+	return s
+}
+
+// Trials holds the results of repeated runs of a benchmark.
+type Trials []BenchmarkResult
+
+// MeasureTrials runs the benchmark the given number of times as described by
+// opts, pausing between each, and returns every result.
+func MeasureTrials(b Benchmark, opts BenchmarkOptions, count int, pause time.Duration) Trials {
+	trials := make(Trials, 0, count)
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			time.Sleep(pause)
+		}
+		trials = append(trials, Measure(b, opts))
+	}
+	return trials
+}
+
+// Summarize summarizes a metric of each trial, such as its rate.
+func (t Trials) Summarize(metric func(BenchmarkResult) float64) Summary {
+	values := make([]float64, len(t))
+	for i, r := range t {
+		values[i] = metric(r)
+	}
+	return Summarize(values)
+}
+
+// Rates summarizes the estimated rate of each trial, in bytes/sec.
+func (t Trials) Rates() Summary {
+	return t.Summarize(func(r BenchmarkResult) float64 { return float64(r.Rate) })
+}
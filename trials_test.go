@@ -0,0 +1,42 @@
+package speedtest
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func Test_Summarize(t *testing.T) {
+	Convey("Summaries should describe the distribution of values", t, func() {
+		s := Summarize([]float64{4, 1, 3, 2})
+		So(s.N, ShouldEqual, 4)
+		So(s.Min, ShouldEqual, 1)
+		So(s.Max, ShouldEqual, 4)
+		So(s.Median, ShouldEqual, 2.5)
+		So(s.Mean, ShouldEqual, 2.5)
+		So(s.StdDev, ShouldAlmostEqual, 1.2910, 1e-4)
+
+		So(Summarize([]float64{3, 1, 2}).Median, ShouldEqual, 2)
+		So(Summarize([]float64{5}).StdDev, ShouldEqual, 0)
+		So(Summarize(nil), ShouldResemble, Summary{})
+	})
+
+	Convey("Each trial should be measured and summarized", t, func() {
+		b := fakeBenchmark{Chunk: 1000, Interval: 10 * time.Millisecond}
+		opts := BenchmarkOptions{
+			Threads:    1,
+			MaxThreads: 1,
+			Duration:   300 * time.Millisecond,
+		}
+		start := time.Now()
+		trials := MeasureTrials(b, opts, 3, 100*time.Millisecond)
+		So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 1100*time.Millisecond)
+		So(trials, ShouldHaveLength, 3)
+
+		rates := trials.Rates()
+		So(rates.N, ShouldEqual, 3)
+		So(rates.Min, ShouldBeGreaterThan, 0)
+		So(rates.Min, ShouldBeLessThanOrEqualTo, rates.Median)
+		So(rates.Median, ShouldBeLessThanOrEqualTo, rates.Max)
+	})
+}
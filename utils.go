@@ -8,6 +8,9 @@ import (
 const (
 	earthRadius = 6371
 	degToRad    = math.Pi / 180.0
+	// rampFraction is the fraction of the median rate that marks the end of
+	// a ramp.
+	rampFraction = 0.9
 )
 
 // MaximalSumWindow finds the the largest sum of sequential values for the given
@@ -45,6 +48,11 @@ func MaximalSumWindow(data []int, size int) int {
 // MedianSumWindow calculates a median sum of the given window size within
 // the given data.
 func MedianSumWindow(data []int, size int) int {
+	// Reduce window size to that of the input data
+	if size > len(data) {
+		size = len(data)
+	}
+
 	sorted := make([]int, len(data))
 	copy(sorted, data)
 	sort.Ints(sorted)
@@ -56,9 +64,51 @@ func MedianSumWindow(data []int, size int) int {
 	return sum
 }
 
-// Distance calculates the distance between two geographical positions
+// RampEnd finds the index at which the given data stops ramping up, being the
+// start of the first window whose sum is close to the median sum of all
+// windows of the given size. At most half of the data is considered to be
+// ramping.
+//
+// This function is used to exclude the effects of TCP slow start and
+// connection setup from a set of data.
+func RampEnd(data []int, size int) int {
+	if size > len(data) {
+		return 0
+	}
+
+	// Calculate rolling sums
+	sums := make([]int, len(data)-size+1)
+	for i := 0; i < size; i++ {
+		sums[0] += data[i]
+	}
+	for i := 1; i < len(sums); i++ {
+		sums[i] = sums[i-1] - data[i-1] + data[i+size-1]
+	}
+
+	sorted := make([]int, len(sums))
+	copy(sorted, sums)
+	sort.Ints(sorted)
+	target := float64(sorted[len(sorted)/2]) * rampFraction
+
+	for i, sum := range sums {
+		if i >= len(data)/2 {
+			break
+		}
+		if float64(sum) >= target {
+			return i
+		}
+	}
+	return len(data) / 2
+}
+
+// Distance calculates the great-circle distance in km between two
+// geographical positions given in radians, using the haversine formula.
+// See DistanceDegrees for positions in degrees.
 func Distance(lat1 float64, lon1 float64, lat2 float64, lon2 float64) float64 {
-	return earthRadius * math.Acos(
-		math.Sin(lat1)*math.Sin(lat2)+
-			math.Cos(lat1)*math.Cos(lat2)*math.Cos(lon2-lon1))
+	sinLat := math.Sin((lat2 - lat1) / 2)
+	sinLon := math.Sin((lon2 - lon1) / 2)
+	a := sinLat*sinLat + math.Cos(lat1)*math.Cos(lat2)*sinLon*sinLon
+	// Rounding can push a fractionally outside [0, 1] for antipodal points
+	a = math.Max(0, math.Min(1, a))
+	return earthRadius * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
 }
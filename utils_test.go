@@ -42,3 +42,30 @@ func Test_MedianSumWindow(t *testing.T) {
 		So(MedianSumWindow([]int{5, 1, 2, 3, 4}, 3), ShouldEqual, 9)
 	})
 }
+
+func Test_MedianSumWindowShort(t *testing.T) {
+	Convey("Should restrict window", t, func() {
+		So(MedianSumWindow([]int{1, 2, 3}, 5), ShouldEqual, 6)
+		So(MedianSumWindow([]int{}, 5), ShouldEqual, 0)
+	})
+}
+
+func Test_RampEnd(t *testing.T) {
+	Convey("Should find end of ramp", t, func() {
+		data := []int{0, 1, 2, 4, 8, 10, 10, 10, 10, 10, 10, 10, 10, 10}
+		So(RampEnd(data, 1), ShouldEqual, 5)
+		So(RampEnd(data, 2), ShouldEqual, 4)
+	})
+
+	Convey("Should not find ramp in steady data", t, func() {
+		So(RampEnd([]int{5, 5, 5, 5, 5, 5}, 2), ShouldEqual, 0)
+	})
+
+	Convey("Should consider at most half the data", t, func() {
+		So(RampEnd([]int{1, 2, 3, 4, 5, 6, 100, 100}, 1), ShouldEqual, 4)
+	})
+
+	Convey("Should ignore data shorter than window", t, func() {
+		So(RampEnd([]int{1, 2}, 5), ShouldEqual, 0)
+	})
+}